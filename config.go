@@ -29,18 +29,137 @@ type DistributedConf struct {
 	Dht               DhtConf `koanf:"dht"`
 }
 
+type WeightConf struct {
+	Domain string  `koanf:"domain"`
+	Weight float64 `koanf:"weight"`
+}
+
+type InflightConf struct {
+	Domain string `koanf:"domain"`
+	Max    int    `koanf:"max"`
+}
+
+type WindowConf struct {
+	Domain   string `koanf:"domain"`
+	Days     string `koanf:"days"`
+	Hours    string `koanf:"hours"`
+	TimeZone string `koanf:"time_zone"`
+}
+
 type PolitenessConf struct {
-	MaxActiveQueues      int `koanf:"max_active_queues"`
-	Multiplier           int `koanf:"multiplier"`
-	DefaultSessionBudget int `koanf:"session_budget"`
-	TimeoutMs            int `koanf:"timeout"`
+	MaxActiveQueues      int            `koanf:"max_active_queues"`
+	Multiplier           int            `koanf:"multiplier"`
+	DefaultSessionBudget int            `koanf:"session_budget"`
+	TimeoutMs            int            `koanf:"timeout"`
+	QueueOrder           string         `koanf:"queue_order"`
+	Group                string         `koanf:"group"`
+	Scheduler            string         `koanf:"scheduler"`
+	Weights              []WeightConf   `koanf:"weights"`
+	MaxInflight          []InflightConf `koanf:"max_inflight"`
+	Windows              []WindowConf   `koanf:"windows"`
+}
+
+type RobotsConf struct {
+	CacheSize       int `koanf:"cache_size"`
+	TtlMs           int `koanf:"ttl"`
+	MaxCrawlDelayMs int `koanf:"max_crawl_delay"`
+}
+
+type ScopeRuleConf struct {
+	Action string `koanf:"action"`
+	Type   string `koanf:"type"`
+	Value  string `koanf:"value"`
+}
+
+type ScopeConf struct {
+	Default  string          `koanf:"default"`
+	Rules    []ScopeRuleConf `koanf:"rules"`
+	MaxDepth int             `koanf:"max_depth"`
+	SeedMode string          `koanf:"seed_mode"`
+}
+
+type QuotaLimitConf struct {
+	MaxPages int `koanf:"max_pages"`
+	MaxBytes int `koanf:"max_bytes"`
+}
+
+type QuotaConf struct {
+	Host   QuotaLimitConf `koanf:"host"`
+	Domain QuotaLimitConf `koanf:"domain"`
+	Action string         `koanf:"action"`
+}
+
+type MimeConf struct {
+	SkipExtensions []string `koanf:"skip_extensions"`
+	AllowedTypes   []string `koanf:"allowed_types"`
+	ParseTypes     []string `koanf:"parse_types"`
+}
+
+type TrapsConf struct {
+	Enabled              bool    `koanf:"enabled"`
+	MaxPathDepth         int     `koanf:"max_path_depth"`
+	MaxRepeats           int     `koanf:"max_repeats"`
+	MaxCalendarDates     int     `koanf:"max_calendar_dates"`
+	MaxQueryCombinations int     `koanf:"max_query_combinations"`
+	MaxDiscoveryRatio    float64 `koanf:"max_discovery_ratio"`
+	MinDiscovered        int     `koanf:"min_discovered"`
+	ExcludeOnRatio       bool    `koanf:"exclude_on_ratio"`
+	AutoExclude          bool    `koanf:"auto_exclude"`
+}
+
+type RecrawlConf struct {
+	Enabled           bool `koanf:"enabled"`
+	InitialIntervalMs int  `koanf:"initial_interval"`
+	MinIntervalMs     int  `koanf:"min_interval"`
+	MaxIntervalMs     int  `koanf:"max_interval"`
+}
+
+type BreakerConf struct {
+	Enabled       bool `koanf:"enabled"`
+	CooldownAfter int  `koanf:"cooldown_after"`
+	RetireAfter   int  `koanf:"retire_after"`
+	CooldownMs    int  `koanf:"cooldown"`
+	MaxCooldownMs int  `koanf:"max_cooldown"`
+}
+
+type SeenConf struct {
+	Mode            string `koanf:"mode"`
+	CacheSize       int    `koanf:"cache_size"`
+	FlushIntervalMs int    `koanf:"flush_interval"`
+}
+
+type ControlConf struct {
+	Token string `koanf:"token"`
+}
+
+type ListsConf struct {
+	Block        []string `koanf:"block"`
+	Allow        []string `koanf:"allow"`
+	PurgeBlocked bool     `koanf:"purge_blocked"`
 }
 
 type Config struct {
 	Distributed DistributedConf `koanf:"distributed"`
 	Politeness  PolitenessConf  `koanf:"politeness"`
-	CrawlScope  string          `koanf:"scope"`
+	Robots      RobotsConf      `koanf:"robots"`
+	Quota       QuotaConf       `koanf:"quota"`
+	Mime        MimeConf        `koanf:"mime"`
+	Scope       ScopeConf       `koanf:"scope"`
+	Traps       TrapsConf       `koanf:"traps"`
+	Lists       ListsConf       `koanf:"lists"`
+	Recrawl     RecrawlConf     `koanf:"recrawl"`
+	Breaker     BreakerConf     `koanf:"breaker"`
+	Seen        SeenConf        `koanf:"seen"`
+	Control     ControlConf     `koanf:"control"`
 	Seed        string          `koanf:"seed"`
+	Export      string          `koanf:"export"`
+	Import      string          `koanf:"import"`
+	CrawlLog    string          `koanf:"crawl_log"`
+
+	ShutdownTimeoutMs int `koanf:"shutdown_timeout"`
+	LeaseTtlMs        int `koanf:"lease_ttl"`
+
+	CheckpointIntervalMs int `koanf:"checkpoint_interval"`
 }
 
 func ReadConf() (*Config, error) {
@@ -57,12 +176,40 @@ func ReadConf() (*Config, error) {
 		return strcase.ToSnakeWithIgnore(strings.Replace(replaced, "#", " ", -1), ".") //ugh...
 	}), nil)
 
-	k.Load(posflag.Provider(flags, ".", k), nil)
+	k.Load(posflag.ProviderWithFlag(flags, ".", k, func(f *pflag.Flag) (string, interface{}) {
+		if f.Name == "scope" { // the old regex scope, see legacyScope
+			return "", nil
+		}
+		return f.Name, posflag.FlagVal(flags, f)
+	}), nil)
+
+	pattern := legacyScope(k, flags)
+
 	var conf Config
-	k.Unmarshal("", &conf)
+	if err := k.Unmarshal("", &conf); err != nil {
+		return nil, fmt.Errorf("Invalid configuration: %w", err)
+	}
+	if pattern != "" {
+		conf.Scope.Default = "reject"
+		conf.Scope.Rules = []ScopeRuleConf{{Action: "accept", Type: "regex", Value: pattern}}
+	}
 	return &conf, nil
 }
 
+// legacyScope takes the scope given in the old form, a single regex of the
+// URLs to crawl, out of the configuration. The --scope flag wins over the
+// configuration, like every other flag.
+func legacyScope(k *koanf.Koanf, flags *pflag.FlagSet) string {
+	pattern, isString := k.Get("scope").(string)
+	if isString {
+		k.Delete("scope")
+	}
+	if flags.Changed("scope") {
+		pattern, _ = flags.GetString("scope")
+	}
+	return pattern
+}
+
 func ParseFlags() *pflag.FlagSet {
 	f := pflag.NewFlagSet("config", pflag.ContinueOnError)
 	f.Usage = func() {
@@ -74,7 +221,9 @@ func ParseFlags() *pflag.FlagSet {
 	f.String("distributed.addr", "", "defines node address")
 	f.String("distributed.bootstrap_node", "", "node to bootstrap with")
 	f.String("seed", "", "seed list path")
-	f.String("scope", "", "crawl scope")
+	f.String("scope", "", "regex of the URLs to crawl, replaces the scope rules")
+	f.String("export", "", "export the frontier to a file and exit")
+	f.String("import", "", "merge a frontier export into the frontier and exit")
 
 	f.Parse(os.Args[1:])
 
@@ -3,7 +3,9 @@ package dht
 import (
 	"bytes"
 	"crypto/sha1"
+	"errors"
 	"fmt"
+	"math/big"
 	"net"
 	"sync"
 
@@ -193,6 +195,57 @@ func (d *DHT) findClosestVnode(key []byte) *vnode {
 	return prev
 }
 
+// maxRingWalk bounds the number of vnodes Nodes visits.
+const maxRingWalk = 1 << 14
+
+// Nodes walks the ring from successor to successor and returns the address
+// of every node in it, this one included.
+func (d *DHT) Nodes() ([]string, error) {
+	self := d.peer.GetAddr()
+	nodes := []string{self}
+	found := map[string]bool{self: true}
+
+	d.vnMu.Lock()
+	var start []byte
+	for _, v := range d.vnodes {
+		start = v.GetID()
+		break
+	}
+	d.vnMu.Unlock()
+	if start == nil {
+		return nodes, nil
+	}
+
+	visited := map[string]bool{string(start): true}
+	key := start
+	for i := 0; i < maxRingWalk; i++ {
+		succ, err := d.FindSuccessor(nextKey(key))
+		if err != nil {
+			return nil, err
+		}
+
+		if addr := succ.Addr.String(); !found[addr] {
+			found[addr] = true
+			nodes = append(nodes, addr)
+		}
+
+		if visited[string(succ.Id)] {
+			return nodes, nil
+		}
+		visited[string(succ.Id)] = true
+		key = succ.Id
+	}
+	return nil, errors.New("Ring walk did not come back to the start")
+}
+
+// nextKey is the key right after the given one on the ring.
+func nextKey(key []byte) []byte {
+	ring := new(big.Int).Lsh(big.NewInt(1), sha1.Size*8)
+	next := new(big.Int).SetBytes(key)
+	next.Add(next, big.NewInt(1)).Mod(next, ring)
+	return next.FillBytes(make([]byte, sha1.Size))
+}
+
 func (d *DHT) MakeKey(from []byte) []byte {
 	id := sha1.New()
 	id.Write(from)
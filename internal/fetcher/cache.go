@@ -0,0 +1,66 @@
+package fetcher
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Digest returns the SHA-1 of the body in the base32 form used by WARC
+// payload digests.
+func Digest(body []byte) string {
+	sum := sha1.Sum(body)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// MaxAge returns the freshness lifetime announced by the response: the
+// Cache-Control max-age (or s-maxage), otherwise Expires minus Date. It is
+// zero if the response must not be cached or announces nothing.
+func MaxAge(header http.Header, now time.Time) time.Duration {
+	var maxAge time.Duration
+	hasMaxAge := false
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-store", "no-cache":
+			return 0
+		case "max-age", "s-maxage":
+			seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			if age := time.Duration(seconds) * time.Second; !hasMaxAge || age > maxAge {
+				maxAge = age
+				hasMaxAge = true
+			}
+		}
+	}
+	if hasMaxAge {
+		return maxAge
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	if expires.Before(now) {
+		return 0
+	}
+	return expires.Sub(now)
+}
+
+// LastModified returns the Last-Modified time, or the zero time.
+func LastModified(header http.Header) time.Time {
+	t, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
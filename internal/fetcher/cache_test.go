@@ -0,0 +1,35 @@
+package fetcher
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		header map[string]string
+		want   time.Duration
+	}{
+		{map[string]string{"Cache-Control": "public, max-age=3600"}, time.Hour},
+		{map[string]string{"Cache-Control": "max-age=60, s-maxage=120"}, 2 * time.Minute},
+		{map[string]string{"Cache-Control": "no-store, max-age=60"}, 0},
+		{map[string]string{"Expires": "Mon, 01 Jan 2024 02:00:00 GMT"}, 2 * time.Hour},
+		{map[string]string{"Expires": "Mon, 01 Jan 2024 02:00:00 GMT", "Date": "Mon, 01 Jan 2024 01:30:00 GMT"}, 30 * time.Minute},
+		{map[string]string{"Expires": "0"}, 0},
+		{map[string]string{}, 0},
+	}
+
+	for _, c := range cases {
+		header := http.Header{}
+		for k, v := range c.header {
+			header.Set(k, v)
+		}
+
+		if have := MaxAge(header, now); have != c.want {
+			t.Errorf("Unexpected max age for %v. Have: %s, want: %s", c.header, have, c.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package fetcher
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -9,8 +10,10 @@ import (
 )
 
 type FetchDetails struct {
-	Body []byte
-	TTR  time.Duration
+	Body       []byte
+	TTR        time.Duration
+	StatusCode int
+	Header     http.Header
 }
 
 type Fetcher interface {
@@ -18,18 +21,36 @@ type Fetcher interface {
 	Head(*url.URL) (*http.Response, error)
 }
 
+var ErrTypeNotAllowed error = errors.New("Content type not allowed")
+
 type DefaultFetcher struct {
-	timeout time.Duration
-	client  http.Client
+	timeout      time.Duration
+	client       http.Client
+	allowedTypes MediaTypes
+}
+
+type FetcherOption func(*DefaultFetcher)
+
+// WithAllowedTypes makes Fetch abort the download of responses whose
+// Content-Type is not in the set. Responses without a Content-Type are allowed.
+func WithAllowedTypes(types MediaTypes) FetcherOption {
+	return func(df *DefaultFetcher) {
+		df.allowedTypes = types
+	}
 }
 
-func NewDefaultFetcher(timeout time.Duration) *DefaultFetcher {
-	return &DefaultFetcher{
+func NewDefaultFetcher(timeout time.Duration, opts ...FetcherOption) *DefaultFetcher {
+	df := &DefaultFetcher{
 		timeout: timeout,
 		client: http.Client{
 			Timeout: timeout,
 		},
 	}
+
+	for _, fn := range opts {
+		fn(df)
+	}
+	return df
 }
 
 func (df *DefaultFetcher) Fetch(url *url.URL) (*FetchDetails, error) {
@@ -49,14 +70,20 @@ func (df *DefaultFetcher) Fetch(url *url.URL) (*FetchDetails, error) {
 
 	ttr := time.Since(start)
 
+	if mediaType := MediaType(resp.Header); len(df.allowedTypes) > 0 && mediaType != "" && !df.allowedTypes.Contains(mediaType) {
+		return nil, ErrTypeNotAllowed
+	}
+
 	bytes, err := readPage(resp)
 	if err != nil {
 		return nil, err
 	}
 
 	return &FetchDetails{
-		Body: bytes,
-		TTR:  ttr,
+		Body:       bytes,
+		TTR:        ttr,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
 	}, nil
 }
 
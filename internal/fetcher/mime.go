@@ -0,0 +1,56 @@
+package fetcher
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MediaTypes is a set of media types. An entry like "image/*" matches every
+// subtype of its type.
+type MediaTypes map[string]struct{}
+
+func NewMediaTypes(types []string) MediaTypes {
+	set := make(MediaTypes, len(types))
+	for _, t := range types {
+		set[strings.ToLower(strings.TrimSpace(t))] = struct{}{}
+	}
+	return set
+}
+
+func (m MediaTypes) Contains(mediaType string) bool {
+	if _, ok := m[mediaType]; ok {
+		return true
+	}
+
+	typ, _, _ := strings.Cut(mediaType, "/")
+	_, ok := m[typ+"/*"]
+	return ok
+}
+
+// MediaType returns the lowercased media type of the Content-Type header
+// without parameters, or an empty string if there is none.
+func MediaType(header http.Header) string {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// ContentMediaType returns the media type of a response, sniffing the body if
+// the Content-Type header is missing.
+func ContentMediaType(header http.Header, body []byte) string {
+	if mediaType := MediaType(header); mediaType != "" {
+		return mediaType
+	}
+
+	sniffed := make(http.Header)
+	sniffed.Set("Content-Type", http.DetectContentType(body))
+	return MediaType(sniffed)
+}
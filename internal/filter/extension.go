@@ -0,0 +1,22 @@
+package filter
+
+import (
+	"net/url"
+	"strings"
+)
+
+// NewExtensionFilter rejects urls whose path ends with one of the given file
+// extensions, so that known binary files are not even enqueued.
+func NewExtensionFilter(extensions []string) FilterFunc {
+	exts := make(map[string]struct{}, len(extensions))
+	for _, e := range extensions {
+		exts[strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), ".")] = struct{}{}
+	}
+
+	return func(u *url.URL) Decision {
+		if _, skip := exts[Extension(u)]; skip {
+			return Deny("skipped_extension")
+		}
+		return Pass()
+	}
+}
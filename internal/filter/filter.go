@@ -2,107 +2,97 @@ package filter
 
 import (
 	"net/url"
-	"regexp"
-	"sync"
-
-	"github.com/jimsmart/grobotstxt"
-	"github.com/xunterr/aracno/internal/fetcher"
-	"github.com/xunterr/aracno/internal/storage"
-	"github.com/xunterr/aracno/internal/storage/inmem"
+	"time"
 )
 
-type FilterFunc func(url *url.URL) (bool, error)
+type Outcome int
 
-type FilterChain struct {
-	filters []FilterFunc
-}
+const (
+	// OutcomeAccept lets the url through.
+	OutcomeAccept Outcome = iota
+	// OutcomeReject drops the url for good.
+	OutcomeReject
+	// OutcomeRetry drops the url for now; it should be tried again later.
+	OutcomeRetry
+)
 
-func NewFilterChain() *FilterChain {
-	return &FilterChain{}
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeReject:
+		return "reject"
+	case OutcomeRetry:
+		return "retry"
+	default:
+		return "accept"
+	}
 }
 
-func (fc *FilterChain) Append(f ...FilterFunc) {
-	fc.filters = append(fc.filters, f...)
+// Decision is the outcome of a filter. Filter and Reason are set for
+// rejections and retries; Filter is filled in by the chain if left empty.
+type Decision struct {
+	Outcome    Outcome
+	Filter     string
+	Reason     string
+	RetryAfter time.Duration
 }
 
-func (fc *FilterChain) Test(url *url.URL) (bool, error) {
-	for _, f := range fc.filters {
-		ok, err := f(url)
-		if err != nil {
-			return false, err
-		}
-		if !ok {
-			return false, nil
-		}
-	}
-	return true, nil
+func Pass() Decision {
+	return Decision{Outcome: OutcomeAccept}
 }
 
-func NewRegexFilter(regex string) FilterFunc {
-	r := regexp.MustCompile(regex)
-	return func(url *url.URL) (bool, error) {
-		return r.MatchString(url.String()), nil
-	}
+func Deny(reason string) Decision {
+	return Decision{Outcome: OutcomeReject, Reason: reason}
 }
 
-type robotsFilter struct {
-	fetcher fetcher.Fetcher
-	mu      sync.Mutex
-	cache   *inmem.LruCache[string]
+// RetryLater asks for the url to be tried again, not sooner than after.
+func RetryLater(reason string, after time.Duration) Decision {
+	return Decision{Outcome: OutcomeRetry, Reason: reason, RetryAfter: after}
 }
 
-func NewRobotsFilter(fetcher fetcher.Fetcher, cacheSize uint) FilterFunc {
-	cache := inmem.NewLruCache[string](cacheSize)
-	robotsFilter := robotsFilter{
-		cache:   cache,
-		fetcher: fetcher,
-	}
-	return robotsFilter.canCrawl
+func (d Decision) Accepted() bool {
+	return d.Outcome == OutcomeAccept
 }
 
-func (rf *robotsFilter) canCrawl(res *url.URL) (bool, error) {
-	body, err := rf.getRobots(res)
-	if err != nil {
-		return false, err
-	}
+type FilterFunc func(url *url.URL) Decision
 
-	ok := grobotstxt.AgentAllowed(body, "GoBot/1.0", res.String())
-	return ok, nil
+type namedFilter struct {
+	name   string
+	filter FilterFunc
 }
 
-func (rf *robotsFilter) getRobots(url *url.URL) (string, error) {
-	rf.mu.Lock()
-	body, err := rf.cache.Get(url.Hostname())
-	rf.mu.Unlock()
-	if err == nil {
-		return body, nil
-	}
+type FilterChain struct {
+	filters []namedFilter
+}
 
-	if err != storage.NoSuchKeyError {
-		return "", err
-	}
+func NewFilterChain() *FilterChain {
+	return &FilterChain{}
+}
 
-	details, err := rf.fetchRobots(url)
-	if err != nil {
-		return "", err
-	}
+// Append appends a filter whose name is reported in its decisions.
+func (fc *FilterChain) Append(name string, f FilterFunc) {
+	fc.filters = append(fc.filters, namedFilter{name: name, filter: f})
+}
 
-	body = string(details.Body)
+// Check runs the filters in order and returns the decision of the first one
+// that did not accept the url. Every such decision is counted.
+func (fc *FilterChain) Check(url *url.URL) Decision {
+	for _, f := range fc.filters {
+		d := f.filter(url)
+		if d.Accepted() {
+			continue
+		}
 
-	rf.mu.Lock()
-	err = rf.cache.Put(url.Hostname(), body)
-	rf.mu.Unlock()
-	if err != nil {
-		return "", err
+		if d.Filter == "" {
+			d.Filter = f.name
+		}
+		Observe(d)
+		return d
 	}
-	return body, nil
+	return Pass()
 }
 
-func (rf *robotsFilter) fetchRobots(url *url.URL) (*fetcher.FetchDetails, error) {
-	robotsUrl := *url
-	robotsUrl.Path = "/robots.txt"
-	robotsUrl.RawQuery = ""
-	robotsUrl.Fragment = ""
-
-	return rf.fetcher.Fetch(&robotsUrl)
+// Observe counts a decision that did not accept a url. The chain calls it
+// for its own decisions; it is exported for checks made outside of a chain.
+func Observe(d Decision) {
+	filterDecisions.WithLabelValues(d.Filter, d.Outcome.String(), d.Reason).Inc()
 }
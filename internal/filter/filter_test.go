@@ -0,0 +1,31 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFilterChainDecision(t *testing.T) {
+	fc := NewFilterChain()
+	fc.Append("pass", func(u *url.URL) Decision {
+		return Pass()
+	})
+	fc.Append("robots", func(u *url.URL) Decision {
+		return RetryLater("unreachable", time.Minute)
+	})
+	fc.Append("never", func(u *url.URL) Decision {
+		t.Fatalf("Filter called after a decision was made")
+		return Pass()
+	})
+
+	u, _ := url.Parse("https://example.com/")
+	d := fc.Check(u)
+	if d.Outcome != OutcomeRetry || d.Filter != "robots" || d.Reason != "unreachable" || d.RetryAfter != time.Minute {
+		t.Fatalf("Unexpected decision: %+v", d)
+	}
+
+	if !NewFilterChain().Check(u).Accepted() {
+		t.Fatalf("Empty chain must accept")
+	}
+}
@@ -0,0 +1,320 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// List is a set of hosts and url prefixes. A host entry matches the host and
+// all of its subdomains; an entry with a scheme (e.g. https://example.com/a/)
+// matches urls starting with it.
+type List struct {
+	hosts    map[string]struct{}
+	prefixes map[string][]string
+}
+
+func NewList() *List {
+	return &List{
+		hosts:    make(map[string]struct{}),
+		prefixes: make(map[string][]string),
+	}
+}
+
+// ReadList reads one entry per line. Empty lines and lines starting with #
+// are skipped.
+func ReadList(r io.Reader) (*List, error) {
+	l := NewList()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		l.Add(scanner.Text())
+	}
+	return l, scanner.Err()
+}
+
+func (l *List) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.HasPrefix(entry, "#") {
+		return
+	}
+
+	if !strings.Contains(entry, "://") {
+		l.hosts[strings.TrimPrefix(strings.ToLower(entry), ".")] = struct{}{}
+		return
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+	l.prefixes[host] = append(l.prefixes[host], entry)
+}
+
+// Merge adds all entries of other to the list.
+func (l *List) Merge(other *List) {
+	for h := range other.hosts {
+		l.hosts[h] = struct{}{}
+	}
+	for h, p := range other.prefixes {
+		l.prefixes[h] = append(l.prefixes[h], p...)
+	}
+}
+
+func (l *List) Len() int {
+	n := len(l.hosts)
+	for _, p := range l.prefixes {
+		n += len(p)
+	}
+	return n
+}
+
+// MatchesHost reports whether the host or one of its parent domains is a
+// host entry. It takes one lookup per label, whatever the size of the list.
+func (l *List) MatchesHost(host string) bool {
+	host = strings.ToLower(host)
+	for {
+		if _, ok := l.hosts[host]; ok {
+			return true
+		}
+
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+// HasPrefixes reports whether the list has url prefix entries of the host.
+func (l *List) HasPrefixes(host string) bool {
+	_, ok := l.prefixes[strings.ToLower(host)]
+	return ok
+}
+
+func (l *List) Matches(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if l.MatchesHost(host) {
+		return true
+	}
+
+	prefixes, ok := l.prefixes[host]
+	if !ok {
+		return false
+	}
+
+	raw := u.String()
+	for _, p := range prefixes {
+		if strings.HasPrefix(raw, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type listOpts struct {
+	onChange func(prev *List, next *List)
+	debounce time.Duration
+}
+
+type ListOption func(*listOpts)
+
+// WithListChangeHandler sets a function called after the list was reloaded.
+func WithListChangeHandler(handler func(prev *List, next *List)) ListOption {
+	return func(lo *listOpts) {
+		lo.onChange = handler
+	}
+}
+
+// WithReloadDelay sets how long to wait for more changes before reloading.
+func WithReloadDelay(delay time.Duration) ListOption {
+	return func(lo *listOpts) {
+		lo.debounce = delay
+	}
+}
+
+// WatchedList is a list loaded from files and reloaded when they change.
+type WatchedList struct {
+	logger *zap.SugaredLogger
+	name   string
+	paths  []string
+	opts   listOpts
+
+	mu   sync.RWMutex
+	list *List
+	// modTimes are the modification times of the files the list was read from.
+	modTimes map[string]time.Time
+}
+
+func NewWatchedList(logger *zap.Logger, name string, paths []string, opts ...ListOption) (*WatchedList, error) {
+	defaultOpts := listOpts{
+		onChange: func(*List, *List) {},
+		debounce: time.Second,
+	}
+	for _, fn := range opts {
+		fn(&defaultOpts)
+	}
+
+	wl := &WatchedList{
+		logger: logger.Sugar(),
+		name:   name,
+		paths:  paths,
+		opts:   defaultOpts,
+	}
+
+	list, modTimes, err := wl.load()
+	if err != nil {
+		return nil, err
+	}
+	wl.list = list
+	wl.modTimes = modTimes
+	listEntries.WithLabelValues(name).Set(float64(list.Len()))
+	return wl, nil
+}
+
+func (wl *WatchedList) load() (*List, map[string]time.Time, error) {
+	list := NewList()
+	modTimes := make(map[string]time.Time, len(wl.paths))
+	for _, path := range wl.paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		info, err := file.Stat()
+		if err == nil {
+			modTimes[path] = info.ModTime()
+		}
+
+		l, err := ReadList(file)
+		file.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		list.Merge(l)
+	}
+	return list, modTimes, nil
+}
+
+// changed reports whether one of the files was modified since the list was
+// read.
+func (wl *WatchedList) changed() bool {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+	for _, path := range wl.paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(wl.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (wl *WatchedList) List() *List {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+	return wl.list
+}
+
+// Reload reads the files again. The current list is kept if any of them
+// can't be read.
+func (wl *WatchedList) Reload() error {
+	list, modTimes, err := wl.load()
+	if err != nil {
+		return err
+	}
+
+	wl.mu.Lock()
+	prev := wl.list
+	wl.list = list
+	wl.modTimes = modTimes
+	wl.mu.Unlock()
+
+	listEntries.WithLabelValues(wl.name).Set(float64(list.Len()))
+	wl.logger.Infow("List reloaded", "list", wl.name, "entries", list.Len())
+	wl.opts.onChange(prev, list)
+	return nil
+}
+
+// Watch reloads the list whenever one of its files changes, until the
+// context is done. The directories are watched rather than the files, so
+// that files replaced by a rename are picked up too. Files changed before
+// the watch started are reloaded right away.
+func (wl *WatchedList) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	files := make(map[string]struct{})
+	for _, path := range wl.paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		files[abs] = struct{}{}
+
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			return err
+		}
+	}
+
+	var reload <-chan time.Time
+	if wl.changed() {
+		reload = time.After(wl.opts.debounce)
+	}
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if _, watched := files[filepath.Clean(event.Name)]; !watched || event.Op == fsnotify.Chmod {
+				continue
+			}
+			reload = time.After(wl.opts.debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			wl.logger.Errorw("List watcher error", "list", wl.name, "error", err)
+		case <-reload:
+			reload = nil
+			if err := wl.Reload(); err != nil {
+				wl.logger.Errorw("Failed to reload list, keeping the old one", "list", wl.name, "error", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NewBlocklistFilter rejects urls on the list.
+func NewBlocklistFilter(wl *WatchedList) FilterFunc {
+	return func(u *url.URL) Decision {
+		if wl.List().Matches(u) {
+			return Deny("blocked")
+		}
+		return Pass()
+	}
+}
+
+// NewAllowlistFilter rejects urls that are not on the list.
+func NewAllowlistFilter(wl *WatchedList) FilterFunc {
+	return func(u *url.URL) Decision {
+		if !wl.List().Matches(u) {
+			return Deny("not_allowed")
+		}
+		return Pass()
+	}
+}
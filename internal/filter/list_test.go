@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestListMatches(t *testing.T) {
+	list, err := ReadList(strings.NewReader("# comment\n\nexample.com\n.Spam.org\nhttps://forum.net/private/\n"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := map[string]bool{
+		"https://example.com/":           true,
+		"https://a.b.example.com/x":      true,
+		"https://notexample.com/":        false,
+		"http://www.spam.org/":           true,
+		"https://forum.net/private/1":    true,
+		"https://forum.net/public/1":     false,
+		"http://forum.net/private/1":     false,
+		"https://sub.forum.net/private/": false,
+	}
+
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if have := list.Matches(u); have != want {
+			t.Errorf("Unexpected result for %s. Have: %t, want: %t", raw, have, want)
+		}
+	}
+}
+
+func TestWatchedListReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "block.txt")
+	if err := os.WriteFile(path, []byte("example.com\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	changed := make(chan *List, 1)
+	wl, err := NewWatchedList(zap.NewNop(), "test", []string{path},
+		WithReloadDelay(10*time.Millisecond),
+		WithListChangeHandler(func(prev *List, next *List) {
+			changed <- next
+		}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wl.Watch(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("example.com\nspam.org\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	select {
+	case next := <-changed:
+		if !next.MatchesHost("www.spam.org") {
+			t.Fatalf("Reloaded list is missing the new entry")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("List was not reloaded")
+	}
+
+	u, _ := url.Parse("https://spam.org/")
+	if NewBlocklistFilter(wl)(u).Accepted() {
+		t.Fatalf("Blocklist filter accepted a newly blocked host")
+	}
+}
+
+func TestWatchedListChangedBeforeWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "block.txt")
+	if err := os.WriteFile(path, []byte("example.com\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	changed := make(chan *List, 1)
+	wl, err := NewWatchedList(zap.NewNop(), "test", []string{path},
+		WithReloadDelay(10*time.Millisecond),
+		WithListChangeHandler(func(prev *List, next *List) {
+			changed <- next
+		}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := os.WriteFile(path, []byte("example.com\nspam.org\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wl.Watch(ctx)
+
+	select {
+	case next := <-changed:
+		if !next.MatchesHost("spam.org") {
+			t.Fatalf("Reloaded list is missing the new entry")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change made before the watch started was missed")
+	}
+}
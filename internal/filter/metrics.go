@@ -0,0 +1,33 @@
+package filter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	scopeRuleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_scope_rule_hits_total",
+		Help: "The number of urls matched by each scope rule.",
+	}, []string{"rule", "action"})
+
+	trapsDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_traps_detected_total",
+		Help: "The number of urls rejected as crawler traps.",
+	}, []string{"reason"})
+
+	filterDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_filter_decisions_total",
+		Help: "The number of urls rejected or deferred by each filter, per reason.",
+	}, []string{"filter", "outcome", "reason"})
+
+	listEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crawler_list_entries",
+		Help: "The number of entries in each block or allow list.",
+	}, []string{"list"})
+
+	trapRules = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "crawler_trap_rules",
+		Help: "The number of exclusion rules added by trap detection.",
+	})
+)
@@ -0,0 +1,353 @@
+package filter
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jimsmart/grobotstxt"
+	"github.com/xunterr/aracno/internal/fetcher"
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+// maxRobotsSize is the amount of robots.txt we parse, RFC 9309 asks for at least 500 KiB.
+const maxRobotsSize = 500 * 1024
+
+// RobotsEntry is a cached robots.txt together with the way it was obtained.
+type RobotsEntry struct {
+	Body       string
+	StatusCode int
+	CrawlDelay time.Duration
+	FetchedAt  time.Time
+	ExpiresAt  time.Time
+	Failures   int
+}
+
+// Unreachable reports whether the entry describes a server error or a failed
+// fetch, in which case the whole host is treated as disallowed.
+func (e RobotsEntry) Unreachable() bool {
+	return e.StatusCode == 0 || e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+type RobotsStorage storage.Storage[RobotsEntry]
+
+type robotsOpts struct {
+	userAgent       string
+	ttl             time.Duration
+	errorBackoff    time.Duration
+	maxErrorBackoff time.Duration
+	maxCrawlDelay   time.Duration
+	onCrawlDelay    func(*url.URL, time.Duration)
+	onFetch         func(*url.URL, *fetcher.FetchDetails)
+	onSitemaps      func([]*url.URL)
+}
+
+type RobotsOption func(*robotsOpts)
+
+func defaultRobotsOpts() robotsOpts {
+	return robotsOpts{
+		userAgent:       "GoBot/1.0",
+		ttl:             24 * time.Hour,
+		errorBackoff:    time.Minute,
+		maxErrorBackoff: 24 * time.Hour,
+		maxCrawlDelay:   time.Minute,
+		onCrawlDelay:    func(*url.URL, time.Duration) {},
+		onFetch:         func(*url.URL, *fetcher.FetchDetails) {},
+		onSitemaps:      func([]*url.URL) {},
+	}
+}
+
+func WithUserAgent(ua string) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.userAgent = ua
+	}
+}
+
+// WithRobotsTTL sets how long a successfully fetched robots.txt stays valid.
+func WithRobotsTTL(ttl time.Duration) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.ttl = ttl
+	}
+}
+
+// WithErrorBackoff sets the initial and the maximum delay before an
+// unreachable robots.txt is fetched again. The delay doubles on every failure.
+func WithErrorBackoff(initial, max time.Duration) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.errorBackoff = initial
+		ro.maxErrorBackoff = max
+	}
+}
+
+// WithMaxCrawlDelay caps the Crawl-delay value a host can ask for.
+func WithMaxCrawlDelay(max time.Duration) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.maxCrawlDelay = max
+	}
+}
+
+// WithCrawlDelayHandler registers a callback that receives the Crawl-delay
+// of every host that declares one.
+func WithCrawlDelayHandler(fn func(*url.URL, time.Duration)) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.onCrawlDelay = fn
+	}
+}
+
+// WithFetchHandler registers a callback that receives every robots.txt
+// response, e.g. for archiving.
+func WithFetchHandler(fn func(*url.URL, *fetcher.FetchDetails)) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.onFetch = fn
+	}
+}
+
+// WithSitemapHandler registers a callback that receives the sitemaps a
+// robots.txt lists, every time it is fetched.
+func WithSitemapHandler(fn func([]*url.URL)) RobotsOption {
+	return func(ro *robotsOpts) {
+		ro.onSitemaps = fn
+	}
+}
+
+type robotsFilter struct {
+	fetcher fetcher.Fetcher
+	opts    robotsOpts
+	mu      sync.Mutex
+	storage RobotsStorage
+}
+
+func NewRobotsFilter(fetcher fetcher.Fetcher, storage RobotsStorage, opts ...RobotsOption) FilterFunc {
+	defaultOpts := defaultRobotsOpts()
+	for _, fn := range opts {
+		fn(&defaultOpts)
+	}
+
+	robotsFilter := &robotsFilter{
+		fetcher: fetcher,
+		opts:    defaultOpts,
+		storage: storage,
+	}
+	return robotsFilter.canCrawl
+}
+
+// canCrawl disallows the url if robots.txt does, and asks for a retry once
+// the backoff expires if robots.txt is unreachable.
+func (rf *robotsFilter) canCrawl(res *url.URL) Decision {
+	entry, err := rf.getRobots(res)
+	if err != nil {
+		return RetryLater("storage_error", 0)
+	}
+
+	if entry.Unreachable() {
+		return RetryLater("unreachable", time.Until(entry.ExpiresAt))
+	}
+
+	if entry.CrawlDelay > 0 {
+		rf.opts.onCrawlDelay(res, entry.CrawlDelay)
+	}
+
+	if !grobotstxt.AgentAllowed(entry.Body, rf.opts.userAgent, res.String()) {
+		return Deny("disallowed")
+	}
+	return Pass()
+}
+
+func (rf *robotsFilter) getRobots(url *url.URL) (RobotsEntry, error) {
+	key := robotsKey(url)
+
+	rf.mu.Lock()
+	cached, err := rf.storage.Get(key)
+	rf.mu.Unlock()
+	if err != nil && err != storage.NoSuchKeyError {
+		return RobotsEntry{}, err
+	}
+
+	hasCached := err == nil
+	if hasCached && time.Now().Before(cached.ExpiresAt) {
+		return cached, nil
+	}
+
+	entry := rf.fetchRobots(url, cached)
+
+	rf.mu.Lock()
+	err = rf.storage.Put(key, entry)
+	rf.mu.Unlock()
+	if err != nil {
+		return RobotsEntry{}, err
+	}
+	return entry, nil
+}
+
+// fetchRobots fetches and interprets robots.txt following RFC 9309: 2xx is
+// parsed, any other 4xx means there are no restrictions, and 5xx, 429 or a
+// network error disallow the whole host until the next retry.
+func (rf *robotsFilter) fetchRobots(url *url.URL, prev RobotsEntry) RobotsEntry {
+	robotsUrl := *url
+	robotsUrl.Path = "/robots.txt"
+	robotsUrl.RawPath = ""
+	robotsUrl.RawQuery = ""
+	robotsUrl.Fragment = ""
+
+	now := time.Now()
+	details, err := rf.fetcher.Fetch(&robotsUrl)
+	if err != nil {
+		return rf.failedEntry(prev, 0, now)
+	}
+
+	rf.opts.onFetch(&robotsUrl, details)
+
+	entry := RobotsEntry{
+		StatusCode: details.StatusCode,
+		FetchedAt:  now,
+		ExpiresAt:  now.Add(rf.opts.ttl),
+	}
+
+	switch {
+	case entry.Unreachable():
+		return rf.failedEntry(prev, details.StatusCode, now)
+	case details.StatusCode >= 200 && details.StatusCode < 300:
+		body := details.Body
+		if len(body) > maxRobotsSize {
+			body = body[:maxRobotsSize]
+		}
+		entry.Body = string(body)
+		entry.CrawlDelay = rf.crawlDelay(entry.Body)
+		if sitemaps := sitemaps(&robotsUrl, entry.Body); len(sitemaps) > 0 {
+			rf.opts.onSitemaps(sitemaps)
+		}
+	}
+
+	return entry
+}
+
+func (rf *robotsFilter) failedEntry(prev RobotsEntry, status int, now time.Time) RobotsEntry {
+	failures := prev.Failures + 1
+	if !prev.Unreachable() {
+		failures = 1
+	}
+
+	backoff := rf.opts.errorBackoff
+	for i := 1; i < failures && backoff < rf.opts.maxErrorBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > rf.opts.maxErrorBackoff {
+		backoff = rf.opts.maxErrorBackoff
+	}
+
+	return RobotsEntry{
+		StatusCode: status,
+		FetchedAt:  now,
+		ExpiresAt:  now.Add(backoff),
+		Failures:   failures,
+	}
+}
+
+func (rf *robotsFilter) crawlDelay(body string) time.Duration {
+	delay := newCrawlDelayExtractor(rf.opts.userAgent).CrawlDelay(body)
+	if delay > rf.opts.maxCrawlDelay {
+		return rf.opts.maxCrawlDelay
+	}
+	return delay
+}
+
+// sitemaps returns the sitemaps listed by a robots.txt, resolved against its url.
+func sitemaps(robotsUrl *url.URL, body string) []*url.URL {
+	var found []*url.URL
+	for _, raw := range grobotstxt.Sitemaps(body) {
+		u, err := robotsUrl.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		found = append(found, u)
+	}
+	return found
+}
+
+func robotsKey(url *url.URL) string {
+	return url.Scheme + "://" + url.Host
+}
+
+// crawlDelayExtractor picks the Crawl-delay of the most specific group that
+// applies to the user agent, the same way grobotstxt picks allow rules.
+type crawlDelayExtractor struct {
+	agent string
+
+	inGroup      bool
+	groupMatches bool
+	groupGlobal  bool
+
+	specific     time.Duration
+	specificSeen bool
+	global       time.Duration
+	globalSeen   bool
+}
+
+func newCrawlDelayExtractor(userAgent string) *crawlDelayExtractor {
+	agent, _, _ := strings.Cut(userAgent, "/")
+	return &crawlDelayExtractor{
+		agent: strings.ToLower(agent),
+	}
+}
+
+func (e *crawlDelayExtractor) CrawlDelay(robotsBody string) time.Duration {
+	grobotstxt.Parse(robotsBody, e)
+	if e.specificSeen {
+		return e.specific
+	}
+	return e.global
+}
+
+func (e *crawlDelayExtractor) HandleRobotsStart() {}
+
+func (e *crawlDelayExtractor) HandleRobotsEnd() {}
+
+func (e *crawlDelayExtractor) HandleUserAgent(lineNum int, value string) {
+	if !e.inGroup {
+		e.inGroup = true
+		e.groupMatches = false
+		e.groupGlobal = false
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "*" {
+		e.groupGlobal = true
+	} else if value == e.agent {
+		e.groupMatches = true
+	}
+}
+
+func (e *crawlDelayExtractor) HandleAllow(lineNum int, value string) {
+	e.inGroup = false
+}
+
+func (e *crawlDelayExtractor) HandleDisallow(lineNum int, value string) {
+	e.inGroup = false
+}
+
+func (e *crawlDelayExtractor) HandleSitemap(lineNum int, value string) {}
+
+func (e *crawlDelayExtractor) HandleUnknownAction(lineNum int, action, value string) {
+	e.inGroup = false
+	if !strings.EqualFold(action, "crawl-delay") {
+		return
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds < 0 {
+		return
+	}
+
+	delay := time.Duration(seconds * float64(time.Second))
+	if e.groupMatches && !e.specificSeen {
+		e.specific = delay
+		e.specificSeen = true
+	}
+	if e.groupGlobal && !e.globalSeen {
+		e.global = delay
+		e.globalSeen = true
+	}
+}
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/xunterr/aracno/internal/fetcher"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+type stubFetcher struct {
+	status int
+	body   string
+	err    error
+	calls  int
+}
+
+func (sf *stubFetcher) Fetch(*url.URL) (*fetcher.FetchDetails, error) {
+	sf.calls++
+	if sf.err != nil {
+		return nil, sf.err
+	}
+	return &fetcher.FetchDetails{
+		Body:       []byte(sf.body),
+		StatusCode: sf.status,
+	}, nil
+}
+
+func (sf *stubFetcher) Head(*url.URL) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func testRobots(t *testing.T, f *stubFetcher, opts ...RobotsOption) Decision {
+	robots := NewRobotsFilter(f, inmem.NewInMemoryStorage[RobotsEntry](), opts...)
+	u, _ := url.Parse("https://example.com/private/page")
+	return robots(u)
+}
+
+func TestRobotsStatus(t *testing.T) {
+	body := "User-agent: *\nDisallow: /private\n"
+
+	cases := []struct {
+		status int
+		want   Outcome
+	}{
+		{200, OutcomeReject},
+		{404, OutcomeAccept},
+		{403, OutcomeAccept},
+		{429, OutcomeRetry},
+		{503, OutcomeRetry},
+	}
+
+	for _, c := range cases {
+		if have := testRobots(t, &stubFetcher{status: c.status, body: body}).Outcome; have != c.want {
+			t.Errorf("Unexpected result for status %d. Have: %s, want: %s", c.status, have, c.want)
+		}
+	}
+
+	d := testRobots(t, &stubFetcher{err: errors.New("connection refused")})
+	if d.Accepted() {
+		t.Errorf("Unreachable robots.txt must disallow crawling")
+	}
+	if d.Outcome != OutcomeRetry || d.RetryAfter <= 0 {
+		t.Errorf("Unreachable robots.txt must be retried after the backoff. Have: %s after %s", d.Outcome, d.RetryAfter)
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	f := &stubFetcher{status: 200}
+	robots := NewRobotsFilter(f, inmem.NewInMemoryStorage[RobotsEntry](), WithRobotsTTL(time.Hour))
+	u, _ := url.Parse("https://example.com/")
+	robots(u)
+	robots(u)
+	if f.calls != 1 {
+		t.Fatalf("Unexpected number of fetches. Have: %d, want: 1", f.calls)
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	body := "User-agent: *\nCrawl-delay: 10\n\nUser-agent: GoBot\nUser-agent: other\nCrawl-delay: 2.5\nDisallow: /x\n"
+
+	var delay time.Duration
+	testRobots(t, &stubFetcher{status: 200, body: body}, WithCrawlDelayHandler(func(u *url.URL, d time.Duration) {
+		delay = d
+	}))
+
+	if delay != 2500*time.Millisecond {
+		t.Fatalf("Unexpected crawl delay. Have: %s, want: 2.5s", delay)
+	}
+
+	testRobots(t, &stubFetcher{status: 200, body: body}, WithUserAgent("Unknown/1.0"), WithMaxCrawlDelay(5*time.Second),
+		WithCrawlDelayHandler(func(u *url.URL, d time.Duration) {
+			delay = d
+		}))
+
+	if delay != 5*time.Second {
+		t.Fatalf("Unexpected crawl delay. Have: %s, want: 5s", delay)
+	}
+}
+
+func TestRobotsSitemaps(t *testing.T) {
+	body := "User-agent: *\nDisallow: /x\n\nSitemap: https://example.com/sitemap.xml\nsitemap: /news.xml # relative\n"
+
+	var sitemaps []string
+	testRobots(t, &stubFetcher{status: 200, body: body}, WithSitemapHandler(func(listed []*url.URL) {
+		for _, u := range listed {
+			sitemaps = append(sitemaps, u.String())
+		}
+	}))
+
+	if len(sitemaps) != 2 || sitemaps[0] != "https://example.com/sitemap.xml" || sitemaps[1] != "https://example.com/news.xml" {
+		t.Fatalf("Unexpected sitemaps: %v", sitemaps)
+	}
+}
@@ -0,0 +1,218 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Action int
+
+const (
+	Reject Action = iota
+	Accept
+)
+
+func ParseAction(action string) (Action, error) {
+	switch strings.ToLower(action) {
+	case "accept":
+		return Accept, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return Reject, fmt.Errorf("Unknown scope action: %q", action)
+	}
+}
+
+func (a Action) String() string {
+	if a == Accept {
+		return "accept"
+	}
+	return "reject"
+}
+
+type matcher func(*url.URL) bool
+
+// Rule is a single scope rule. A url that matches the rule gets the rule's action.
+type Rule struct {
+	Action Action
+	Name   string
+	match  matcher
+}
+
+func (r Rule) Matches(u *url.URL) bool {
+	return r.match(u)
+}
+
+// NewRule builds a scope rule of the given type. Supported types are:
+//   - host: the url host is equal to value
+//   - domain: the url host is value or one of its subdomains
+//   - path_prefix: the url path starts with value
+//   - regex: the whole url matches the regular expression
+//   - extension: the path has one of the comma-separated file extensions
+//   - max_length: the url is longer than value
+//   - max_query_params: the url has more than value query parameters
+//   - port: the url port (explicit or implied by the scheme) is equal to value
+//   - scheme: the url scheme is one of the comma-separated schemes
+func NewRule(action Action, kind string, value string) (Rule, error) {
+	match, err := newMatcher(kind, value)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return Rule{
+		Action: action,
+		Name:   fmt.Sprintf("%s:%s", kind, value),
+		match:  match,
+	}, nil
+}
+
+func newMatcher(kind string, value string) (matcher, error) {
+	if value == "" {
+		return nil, fmt.Errorf("Scope rule %q requires a value", kind)
+	}
+
+	switch kind {
+	case "host":
+		host := strings.ToLower(value)
+		return func(u *url.URL) bool {
+			return strings.ToLower(u.Hostname()) == host
+		}, nil
+	case "domain":
+		domain := strings.ToLower(strings.TrimPrefix(value, "."))
+		return func(u *url.URL) bool {
+			return isSubdomain(strings.ToLower(u.Hostname()), domain)
+		}, nil
+	case "path_prefix":
+		return func(u *url.URL) bool {
+			return strings.HasPrefix(u.EscapedPath(), value)
+		}, nil
+	case "regex":
+		r, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(u *url.URL) bool {
+			return r.MatchString(u.String())
+		}, nil
+	case "extension":
+		exts := make(map[string]struct{})
+		for _, e := range splitList(value) {
+			exts[strings.TrimPrefix(strings.ToLower(e), ".")] = struct{}{}
+		}
+		return func(u *url.URL) bool {
+			_, ok := exts[Extension(u)]
+			return ok
+		}, nil
+	case "max_length":
+		max, err := parseLimit(kind, value)
+		if err != nil {
+			return nil, err
+		}
+		return func(u *url.URL) bool {
+			return len(u.String()) > max
+		}, nil
+	case "max_query_params":
+		max, err := parseLimit(kind, value)
+		if err != nil {
+			return nil, err
+		}
+		return func(u *url.URL) bool {
+			return queryParamCount(u) > max
+		}, nil
+	case "port":
+		if _, err := strconv.ParseUint(value, 10, 16); err != nil {
+			return nil, fmt.Errorf("Invalid port in scope rule: %q", value)
+		}
+		return func(u *url.URL) bool {
+			return port(u) == value
+		}, nil
+	case "scheme":
+		schemes := make(map[string]struct{})
+		for _, s := range splitList(value) {
+			schemes[strings.ToLower(s)] = struct{}{}
+		}
+		return func(u *url.URL) bool {
+			_, ok := schemes[strings.ToLower(u.Scheme)]
+			return ok
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown scope rule type: %q", kind)
+	}
+}
+
+// NewScopeFilter returns a filter that applies the first matching rule, or
+// the default action when no rule matches. Rejections carry the rule name as
+// their reason.
+func NewScopeFilter(rules []Rule, defaultAction Action) FilterFunc {
+	return func(u *url.URL) Decision {
+		for _, r := range rules {
+			if r.Matches(u) {
+				scopeRuleHits.WithLabelValues(r.Name, r.Action.String()).Inc()
+				return r.Action.decide(r.Name)
+			}
+		}
+
+		scopeRuleHits.WithLabelValues("default", defaultAction.String()).Inc()
+		return defaultAction.decide("default")
+	}
+}
+
+func (a Action) decide(rule string) Decision {
+	if a == Accept {
+		return Pass()
+	}
+	return Deny(rule)
+}
+
+// Extension returns the lowercased file extension of the url path without the dot.
+func Extension(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(u.Path)), ".")
+}
+
+func isSubdomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func queryParamCount(u *url.URL) int {
+	if u.RawQuery == "" {
+		return 0
+	}
+	return strings.Count(u.RawQuery, "&") + 1
+}
+
+func port(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	default:
+		return ""
+	}
+}
+
+func parseLimit(kind string, value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("Invalid limit in scope rule %q: %q", kind, value)
+	}
+	return limit, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
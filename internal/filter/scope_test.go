@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+)
+
+func mustRule(t *testing.T, action Action, kind string, value string) Rule {
+	r, err := NewRule(action, kind, value)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return r
+}
+
+func TestScopeFirstMatchWins(t *testing.T) {
+	scope := NewScopeFilter([]Rule{
+		mustRule(t, Reject, "path_prefix", "/admin"),
+		mustRule(t, Reject, "extension", "pdf, .JPG"),
+		mustRule(t, Reject, "max_query_params", "2"),
+		mustRule(t, Accept, "domain", "example.com"),
+		mustRule(t, Accept, "port", "8080"),
+	}, Reject)
+
+	cases := map[string]bool{
+		"https://example.com/":            true,
+		"https://www.example.com/a":       true,
+		"https://notexample.com/":         false,
+		"https://example.com/admin/users": false,
+		"https://example.com/file.pdf":    false,
+		"https://example.com/photo.jpg":   false,
+		"https://example.com/?a=1&b=2":    true,
+		"https://example.com/?a=1&b=2&c":  false,
+		"http://other.org:8080/":          true,
+		"http://other.org/":               false,
+	}
+
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if have := scope(u).Accepted(); have != want {
+			t.Errorf("Unexpected result for %s. Have: %t, want: %t", raw, have, want)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	invalid := [][2]string{
+		{"regex", "(("},
+		{"max_length", "many"},
+		{"port", "99999"},
+		{"unknown", "value"},
+		{"host", ""},
+	}
+
+	for _, r := range invalid {
+		if _, err := NewRule(Accept, r[0], r[1]); err == nil {
+			t.Errorf("Expected an error for rule %s: %q", r[0], r[1])
+		}
+	}
+}
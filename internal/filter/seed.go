@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/xunterr/aracno/internal/hostname"
+)
+
+type SeedScopeMode int
+
+const (
+	// SeedScopeNone disables the seed scope.
+	SeedScopeNone SeedScopeMode = iota
+	// SeedScopeDomain accepts urls on the registered domains (eTLD+1) of the seeds.
+	SeedScopeDomain
+	// SeedScopeHost accepts urls on the exact hosts of the seeds.
+	SeedScopeHost
+	// SeedScopePrefix accepts urls under the path prefixes of the seeds.
+	SeedScopePrefix
+)
+
+func ParseSeedScopeMode(mode string) (SeedScopeMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "none":
+		return SeedScopeNone, nil
+	case "domain":
+		return SeedScopeDomain, nil
+	case "host":
+		return SeedScopeHost, nil
+	case "prefix":
+		return SeedScopePrefix, nil
+	default:
+		return SeedScopeNone, fmt.Errorf("Unknown seed scope mode: %q", mode)
+	}
+}
+
+func (m SeedScopeMode) String() string {
+	switch m {
+	case SeedScopeDomain:
+		return "domain"
+	case SeedScopeHost:
+		return "host"
+	case SeedScopePrefix:
+		return "prefix"
+	default:
+		return "none"
+	}
+}
+
+// NewSeedScopeFilter returns a filter that keeps the crawl on the seeds:
+// their registered domains, their hosts or their path prefixes depending on
+// the mode. Registered domains are resolved with the Public Suffix List
+// embedded in golang.org/x/net/publicsuffix.
+func NewSeedScopeFilter(mode SeedScopeMode, seeds []*url.URL) FilterFunc {
+	if mode == SeedScopeNone {
+		return func(u *url.URL) Decision {
+			return Pass()
+		}
+	}
+
+	prefixes := make(map[string][]string)
+	for _, s := range seeds {
+		key := seedScopeKey(mode, s)
+		if mode == SeedScopePrefix {
+			prefixes[key] = append(prefixes[key], pathPrefix(s.EscapedPath()))
+		} else {
+			prefixes[key] = nil
+		}
+	}
+
+	label := "seed:" + mode.String()
+	return func(u *url.URL) Decision {
+		if inSeedScope(mode, prefixes, u) {
+			scopeRuleHits.WithLabelValues(label, Accept.String()).Inc()
+			return Pass()
+		}
+
+		scopeRuleHits.WithLabelValues(label, Reject.String()).Inc()
+		return Deny("outside_seed_" + mode.String())
+	}
+}
+
+func inSeedScope(mode SeedScopeMode, prefixes map[string][]string, u *url.URL) bool {
+	paths, ok := prefixes[seedScopeKey(mode, u)]
+	if !ok || mode != SeedScopePrefix {
+		return ok
+	}
+
+	path := u.EscapedPath()
+	for _, p := range paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func seedScopeKey(mode SeedScopeMode, u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if mode == SeedScopeDomain {
+		return hostname.RegisteredDomain(host)
+	}
+	return host
+}
+
+// pathPrefix returns the directory part of the path, e.g. "/docs/" for
+// "/docs/intro.html".
+func pathPrefix(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "/"
+	}
+	return path[:i+1]
+}
@@ -0,0 +1,43 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSeedScope(t *testing.T) {
+	var seeds []*url.URL
+	for _, raw := range []string{"https://www.example.co.uk/", "http://blog.example.com/docs/intro.html"} {
+		u, _ := url.Parse(raw)
+		seeds = append(seeds, u)
+	}
+
+	cases := map[SeedScopeMode]map[string]bool{
+		SeedScopeDomain: {
+			"https://shop.example.co.uk/a":  true,
+			"https://other.co.uk/":          false,
+			"https://example.com/":          true,
+			"https://example.com.evil.org/": false,
+		},
+		SeedScopeHost: {
+			"https://www.example.co.uk/a":  true,
+			"https://shop.example.co.uk/a": false,
+			"https://BLOG.example.com/x":   true,
+		},
+		SeedScopePrefix: {
+			"https://www.example.co.uk/any":     true,
+			"https://blog.example.com/docs/api": true,
+			"https://blog.example.com/news":     false,
+		},
+	}
+
+	for mode, urls := range cases {
+		scope := NewSeedScopeFilter(mode, seeds)
+		for raw, want := range urls {
+			u, _ := url.Parse(raw)
+			if have := scope(u).Accepted(); have != want {
+				t.Errorf("Unexpected result for %s in %s mode. Have: %t, want: %t", raw, mode, have, want)
+			}
+		}
+	}
+}
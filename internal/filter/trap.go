@@ -0,0 +1,417 @@
+package filter
+
+import (
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage/inmem"
+	"go.uber.org/zap"
+)
+
+var dateRegex = regexp.MustCompile(`\b(?:19|20)\d{2}[-/_.]?(?:0[1-9]|1[0-2])(?:[-/_.]?(?:0[1-9]|[12]\d|3[01]))?\b`)
+
+var dateParams = map[string]struct{}{
+	"year":  {},
+	"month": {},
+	"day":   {},
+	"date":  {},
+	"week":  {},
+}
+
+const (
+	// maxTrackedKeys bounds the number of paths tracked per host.
+	maxTrackedKeys = 10_000
+	// maxTrackedHosts bounds the number of hosts tracked at a time. The
+	// counts of the least recently added hosts are dropped first.
+	maxTrackedHosts = 100_000
+	// maxExcludedPatterns bounds the number of exclusion rules. The oldest
+	// rules are dropped first.
+	maxExcludedPatterns = 10_000
+)
+
+type trapOpts struct {
+	maxPathDepth         int
+	maxRepeats           int
+	maxCalendarDates     int
+	maxYearsAhead        int
+	maxQueryCombinations int
+	maxDiscoveryRatio    float64
+	minDiscovered        uint64
+	excludeOnRatio       bool
+	autoExclude          bool
+}
+
+type TrapOption func(*trapOpts)
+
+func defaultTrapOpts() trapOpts {
+	return trapOpts{
+		maxPathDepth:         16,
+		maxRepeats:           3,
+		maxCalendarDates:     500,
+		maxYearsAhead:        1,
+		maxQueryCombinations: 32,
+		maxDiscoveryRatio:    100,
+		minDiscovered:        1000,
+		autoExclude:          true,
+	}
+}
+
+// WithMaxPathDepth sets the maximum number of path segments.
+func WithMaxPathDepth(depth int) TrapOption {
+	return func(to *trapOpts) {
+		to.maxPathDepth = depth
+	}
+}
+
+// WithMaxRepeats sets how many times a sequence of path segments may repeat
+// in a row, as in /a/b/a/b/a/b.
+func WithMaxRepeats(repeats int) TrapOption {
+	return func(to *trapOpts) {
+		to.maxRepeats = repeats
+	}
+}
+
+// WithMaxCalendarDates sets how many distinct dates a single calendar-like
+// url pattern may have.
+func WithMaxCalendarDates(dates int) TrapOption {
+	return func(to *trapOpts) {
+		to.maxCalendarDates = dates
+	}
+}
+
+// WithMaxQueryCombinations sets how many distinct sets of query parameters a
+// single path may have.
+func WithMaxQueryCombinations(combinations int) TrapOption {
+	return func(to *trapOpts) {
+		to.maxQueryCombinations = combinations
+	}
+}
+
+// WithMaxDiscoveryRatio flags hosts that have more than ratio newly enqueued
+// urls per fetched one, once they have at least minDiscovered urls. Flagged
+// hosts are only logged, unless WithDiscoveryRatioExclude is set.
+func WithMaxDiscoveryRatio(ratio float64, minDiscovered uint64) TrapOption {
+	return func(to *trapOpts) {
+		to.maxDiscoveryRatio = ratio
+		to.minDiscovered = minDiscovered
+	}
+}
+
+// WithDiscoveryRatioExclude sets whether a host flagged for its discovery
+// ratio is excluded as a whole, given that auto exclusion is enabled.
+func WithDiscoveryRatioExclude(exclude bool) TrapOption {
+	return func(to *trapOpts) {
+		to.excludeOnRatio = exclude
+	}
+}
+
+// WithAutoExclude sets whether detected patterns become exclusion rules.
+// Without them, only urls that are traps by themselves are rejected.
+func WithAutoExclude(autoExclude bool) TrapOption {
+	return func(to *trapOpts) {
+		to.autoExclude = autoExclude
+	}
+}
+
+type hostTraps struct {
+	discovered uint64
+	fetched    uint64
+	// ratioFlagged is set once the discovery ratio of the host was exceeded.
+	ratioFlagged bool
+	// urls holds the hashes of the discovered urls, up to maxTrackedKeys.
+	urls map[uint64]struct{}
+
+	calendars map[string]map[string]struct{}
+	queries   map[string]map[string]struct{}
+}
+
+// TrapDetector detects crawler traps: repeating path segments, very deep
+// paths, calendars, exploding query parameter combinations and hosts whose
+// discovered url count grows far faster than their fetched count.
+type TrapDetector struct {
+	logger *zap.SugaredLogger
+	opts   trapOpts
+
+	mu      sync.Mutex
+	hosts   *inmem.LruCache[*hostTraps]
+	flagged *inmem.LruCache[string]
+}
+
+func NewTrapDetector(logger *zap.Logger, opts ...TrapOption) *TrapDetector {
+	defaultOpts := defaultTrapOpts()
+	for _, fn := range opts {
+		fn(&defaultOpts)
+	}
+
+	return &TrapDetector{
+		logger:  logger.Sugar(),
+		opts:    defaultOpts,
+		hosts:   inmem.NewLruCache[*hostTraps](maxTrackedHosts),
+		flagged: inmem.NewLruCache[string](maxExcludedPatterns),
+	}
+}
+
+// Filter returns a filter that rejects urls that look like traps.
+func (td *TrapDetector) Filter() FilterFunc {
+	return func(u *url.URL) Decision {
+		if reason, ok := td.check(u); !ok {
+			trapsDetected.WithLabelValues(reason).Inc()
+			return Deny(reason)
+		}
+		return Pass()
+	}
+}
+
+// Discovered records a url that was newly enqueued. Only distinct urls of
+// hosts with fetched urls are counted, up to maxTrackedKeys per host.
+func (td *TrapDetector) Discovered(u *url.URL) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	host, err := td.hosts.Get(u.Hostname())
+	if err != nil || host.fetched == 0 || len(host.urls) >= maxTrackedKeys {
+		return
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(u.String()))
+	if _, ok := host.urls[h.Sum64()]; ok {
+		return
+	}
+	host.urls[h.Sum64()] = struct{}{}
+	host.discovered++
+
+	if host.ratioFlagged || host.discovered < td.opts.minDiscovered || td.opts.maxDiscoveryRatio <= 0 {
+		return
+	}
+
+	if float64(host.discovered)/float64(host.fetched) > td.opts.maxDiscoveryRatio {
+		host.ratioFlagged = true
+		if td.opts.excludeOnRatio {
+			td.flag(u, u.Hostname(), "discovery_ratio")
+			return
+		}
+		td.logger.Infow("Crawler trap suspected", "host", u.Hostname(), "reason", "discovery_ratio",
+			"discovered", host.discovered, "fetched", host.fetched)
+	}
+}
+
+// Fetched records a fetched url.
+func (td *TrapDetector) Fetched(u *url.URL) {
+	td.mu.Lock()
+	td.host(u.Hostname()).fetched++
+	td.mu.Unlock()
+}
+
+func (td *TrapDetector) check(u *url.URL) (string, bool) {
+	segments := pathSegments(u.Path)
+	if td.opts.maxPathDepth > 0 && len(segments) > td.opts.maxPathDepth {
+		return td.reject(u, "path_depth")
+	}
+
+	if td.opts.maxRepeats > 0 && hasRepeats(segments, td.opts.maxRepeats) {
+		return td.reject(u, "repeating_segments")
+	}
+
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	hostname := u.Hostname()
+	if reason, ok := td.excluded(hostname); ok {
+		return reason, false
+	}
+	if reason, ok := td.excluded(hostname + u.Path); ok {
+		return reason, false
+	}
+
+	if template, dates, years := calendarPattern(u); template != "" {
+		key := hostname + template
+		if reason, ok := td.excluded(key); ok {
+			return reason, false
+		}
+
+		for _, year := range years {
+			if year > time.Now().Year()+td.opts.maxYearsAhead {
+				return td.reject(u, "calendar")
+			}
+		}
+
+		if td.opts.maxCalendarDates > 0 && td.track(td.host(hostname).calendars, key, dates) > td.opts.maxCalendarDates {
+			if td.flag(u, key, "calendar") {
+				return "calendar", false
+			}
+		}
+	}
+
+	if td.opts.maxQueryCombinations > 0 && u.RawQuery != "" {
+		key := hostname + u.Path
+		if td.track(td.host(hostname).queries, key, queryNames(u)) > td.opts.maxQueryCombinations {
+			if td.flag(u, key, "query_combinations") {
+				return "query_combinations", false
+			}
+		}
+	}
+
+	return "", true
+}
+
+// excluded returns the reason the key was excluded for, if it was.
+// Must be called with td.mu held.
+func (td *TrapDetector) excluded(key string) (string, bool) {
+	if !td.opts.autoExclude {
+		return "", false
+	}
+	reason, err := td.flagged.Get(key)
+	return reason, err == nil
+}
+
+func (td *TrapDetector) reject(u *url.URL, reason string) (string, bool) {
+	td.logger.Debugw("Crawler trap detected", "url", u.String(), "reason", reason)
+	return reason, false
+}
+
+// flag records a detected trap pattern and, if auto exclusion is enabled,
+// turns it into an exclusion rule. It reports whether the pattern is excluded.
+// Must be called with td.mu held.
+func (td *TrapDetector) flag(u *url.URL, key string, reason string) bool {
+	if _, err := td.flagged.Get(key); err == nil {
+		return td.opts.autoExclude
+	}
+	td.flagged.Put(key, reason)
+
+	if !td.opts.autoExclude {
+		td.logger.Infow("Crawler trap detected", "url", u.String(), "reason", reason, "pattern", key)
+		return false
+	}
+
+	trapRules.Inc()
+	td.logger.Infow("Crawler trap detected, excluding pattern", "url", u.String(), "reason", reason, "rule", key)
+	return true
+}
+
+// track adds the value to the set stored under key and returns the set size.
+// Must be called with td.mu held.
+func (td *TrapDetector) track(sets map[string]map[string]struct{}, key string, value string) int {
+	set, ok := sets[key]
+	if !ok {
+		if len(sets) >= maxTrackedKeys {
+			return 0
+		}
+		set = make(map[string]struct{})
+		sets[key] = set
+	}
+
+	set[value] = struct{}{}
+	return len(set)
+}
+
+func (td *TrapDetector) host(hostname string) *hostTraps {
+	host, err := td.hosts.Get(hostname)
+	if err != nil {
+		host = &hostTraps{
+			calendars: make(map[string]map[string]struct{}),
+			queries:   make(map[string]map[string]struct{}),
+			urls:      make(map[uint64]struct{}),
+		}
+		td.hosts.Put(hostname, host)
+	}
+	return host
+}
+
+func pathSegments(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// hasRepeats reports whether a sequence of up to three segments repeats at
+// least maxRepeats times in a row.
+func hasRepeats(segments []string, maxRepeats int) bool {
+	for size := 1; size <= 3; size++ {
+		for start := 0; start+size*maxRepeats <= len(segments); start++ {
+			repeats := 1
+			for next := start + size; next+size <= len(segments); next += size {
+				if !equalSegments(segments[start:start+size], segments[next:next+size]) {
+					break
+				}
+				repeats++
+			}
+
+			if repeats >= maxRepeats {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func equalSegments(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// calendarPattern returns the url with its dates replaced by a placeholder,
+// the dates themselves and the years found. The template is empty if the url
+// contains no dates.
+func calendarPattern(u *url.URL) (template string, dates string, years []int) {
+	var found []string
+	collect := func(date string) string {
+		found = append(found, date)
+		if year, err := strconv.Atoi(date[:4]); err == nil {
+			years = append(years, year)
+		}
+		return "{date}"
+	}
+
+	path := dateRegex.ReplaceAllStringFunc(u.Path, collect)
+
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name, values := range query {
+		names = append(names, name)
+		for _, v := range values {
+			if _, ok := dateParams[strings.ToLower(name)]; ok {
+				if n, err := strconv.Atoi(v); err == nil {
+					found = append(found, name+"="+v)
+					if strings.EqualFold(name, "year") {
+						years = append(years, n)
+					}
+					continue
+				}
+			}
+			dateRegex.ReplaceAllStringFunc(v, collect)
+		}
+	}
+
+	if len(found) == 0 {
+		return "", "", nil
+	}
+
+	sort.Strings(names)
+	sort.Strings(found)
+	return path + "?" + strings.Join(names, "&"), strings.Join(found, ","), years
+}
+
+func queryNames(u *url.URL) string {
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "&")
+}
@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestTrapStructure(t *testing.T) {
+	filter := NewTrapDetector(zap.NewNop(), WithMaxPathDepth(6), WithMaxRepeats(3)).Filter()
+
+	cases := map[string]bool{
+		"https://example.com/a/b/c":           true,
+		"https://example.com/a/b/a/b":         true,
+		"https://example.com/a/b/a/b/a/b":     false,
+		"https://example.com/x/x/x":           false,
+		"https://example.com/1/2/3/4/5/6/7":   false,
+		"https://example.com/blog/2024/05/01": true,
+		"https://example.com/cal/2099-01-01":  false,
+		"https://example.com/cal?year=2020":   true,
+		"https://example.com/cal?year=2999":   false,
+	}
+
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		have := filter(u).Accepted()
+		if have != want {
+			t.Errorf("Unexpected result for %s. Have: %t, want: %t", raw, have, want)
+		}
+	}
+}
+
+func TestTrapCalendar(t *testing.T) {
+	filter := NewTrapDetector(zap.NewNop(), WithMaxCalendarDates(3)).Filter()
+
+	for day := 1; day <= 3; day++ {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/events/2020-01-%02d", day))
+		if ok := filter(u).Accepted(); !ok {
+			t.Fatalf("Url rejected before reaching the limit: %s", u)
+		}
+	}
+
+	u, _ := url.Parse("https://example.com/events/2020-01-04")
+	if ok := filter(u).Accepted(); ok {
+		t.Fatalf("Calendar url accepted after reaching the limit: %s", u)
+	}
+
+	u, _ = url.Parse("https://example.com/events/2020-01-01")
+	if ok := filter(u).Accepted(); ok {
+		t.Fatalf("Excluded calendar pattern accepted: %s", u)
+	}
+}
+
+func TestTrapQueryCombinations(t *testing.T) {
+	filter := NewTrapDetector(zap.NewNop(), WithMaxQueryCombinations(2), WithAutoExclude(false)).Filter()
+
+	for _, raw := range []string{"https://example.com/list?a=1", "https://example.com/list?a=2&b=1", "https://example.com/list?c=1"} {
+		u, _ := url.Parse(raw)
+		if ok := filter(u).Accepted(); !ok {
+			t.Fatalf("Url rejected without auto exclusion: %s", raw)
+		}
+	}
+
+	filter = NewTrapDetector(zap.NewNop(), WithMaxQueryCombinations(2)).Filter()
+	for i, raw := range []string{"https://example.com/list?a=1", "https://example.com/list?a=2&b=1", "https://example.com/list?c=1"} {
+		u, _ := url.Parse(raw)
+		if ok := filter(u).Accepted(); ok != (i < 2) {
+			t.Fatalf("Unexpected result for %s. Have: %t, want: %t", raw, ok, i < 2)
+		}
+	}
+}
+
+func TestTrapDiscoveryRatio(t *testing.T) {
+	discover := func(td *TrapDetector, host string, n int) {
+		for i := 0; i < n; i++ {
+			td.Discovered(&url.URL{Scheme: "https", Host: host, Path: fmt.Sprintf("/%d", i)})
+		}
+	}
+
+	td := NewTrapDetector(zap.NewNop(), WithMaxDiscoveryRatio(10, 20), WithDiscoveryRatioExclude(true))
+	filter := td.Filter()
+
+	td.Fetched(&url.URL{Scheme: "https", Host: "example.com", Path: "/"})
+	// duplicates are not counted
+	discover(td, "example.com", 19)
+	discover(td, "example.com", 19)
+	u, _ := url.Parse("https://example.com/new")
+	if ok := filter(u).Accepted(); !ok {
+		t.Fatalf("Host flagged for duplicate urls")
+	}
+
+	discover(td, "example.com", 21)
+	// hosts that were never fetched are not checked
+	discover(td, "linked.com", 100)
+
+	u, _ = url.Parse("https://example.com/new")
+	if ok := filter(u).Accepted(); ok {
+		t.Fatalf("Url of a flagged host accepted")
+	}
+
+	for _, raw := range []string{"https://other.com/new", "https://linked.com/new"} {
+		u, _ = url.Parse(raw)
+		if ok := filter(u).Accepted(); !ok {
+			t.Fatalf("Url of an unflagged host rejected: %s", raw)
+		}
+	}
+
+	// without WithDiscoveryRatioExclude the host is only logged
+	td = NewTrapDetector(zap.NewNop(), WithMaxDiscoveryRatio(10, 20))
+	td.Fetched(&url.URL{Scheme: "https", Host: "example.com", Path: "/"})
+	discover(td, "example.com", 21)
+
+	u, _ = url.Parse("https://example.com/new")
+	if ok := td.Filter()(u).Accepted(); !ok {
+		t.Fatalf("Host excluded for its discovery ratio by default")
+	}
+}
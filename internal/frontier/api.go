@@ -0,0 +1,116 @@
+package frontier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultHeadSize = 10
+	// maxHeadSize bounds ?head=, since the head is read from the queue storage.
+	maxHeadSize  = 1000
+	defaultLimit = 1000
+)
+
+// NewApiHandler returns a read-only HTTP/JSON API over the state of the
+// frontier:
+//
+//	GET /frontier                     summary of the queues
+//	GET /frontier/queues              all queues; ?state= filters, ?limit= bounds
+//	GET /frontier/queues/{id}         a queue, its first ?head= urls and bloom stats
+//	GET /frontier/inactive            the ids of queues waiting to become active
+func NewApiHandler(f *BfFrontier) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /frontier", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, f.Stats())
+	})
+
+	mux.HandleFunc("GET /frontier/queues", func(w http.ResponseWriter, r *http.Request) {
+		limit, err := intParam(r, "limit", defaultLimit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		state := QueueState(r.URL.Query().Get("state"))
+		queues := []QueueStats{}
+		for _, q := range f.Queues() {
+			if len(queues) >= limit {
+				break
+			}
+			if state == "" || q.State == state {
+				queues = append(queues, q)
+			}
+		}
+		writeJson(w, http.StatusOK, queues)
+	})
+
+	mux.HandleFunc("GET /frontier/queues/{id}", func(w http.ResponseWriter, r *http.Request) {
+		head, err := intParam(r, "head", defaultHeadSize)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if head > maxHeadSize {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid head: %d is more than %d", head, maxHeadSize))
+			return
+		}
+
+		details, err := f.Queue(r.PathValue("id"), head)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == NoSuchQueueError {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJson(w, http.StatusOK, details)
+	})
+
+	mux.HandleFunc("GET /frontier/inactive", func(w http.ResponseWriter, r *http.Request) {
+		limit, err := intParam(r, "limit", defaultLimit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		ids, err := f.InactiveQueues(limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if ids == nil {
+			ids = []string{}
+		}
+		writeJson(w, http.StatusOK, ids)
+	})
+
+	return mux
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid %s: %q", name, raw)
+	}
+	return n, nil
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,63 @@
+package frontier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getJson(t *testing.T, h http.Handler, path string, v any) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	return rec.Code
+}
+
+func TestApi(t *testing.T) {
+	f := newTestFrontier(WithMaxActiveQueues(1))
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2", "http://b.com/1"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{Depth: 1}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	h := NewApiHandler(f)
+
+	var stats FrontierStats
+	getJson(t, h, "/frontier", &stats)
+	if stats.Queues != 2 || stats.InactiveQueues != 1 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+
+	var queues []QueueStats
+	getJson(t, h, "/frontier/queues?state=active", &queues)
+	if len(queues) != 1 || queues[0].Id != "a.com" || queues[0].Size != 2 || queues[0].NextAccess == nil {
+		t.Fatalf("Unexpected active queues: %+v", queues)
+	}
+
+	var details QueueDetails
+	getJson(t, h, "/frontier/queues/a.com?head=1", &details)
+	if len(details.Head) != 1 || details.Head[0].Url != "http://a.com/1" || details.Head[0].Depth != 1 {
+		t.Fatalf("Unexpected queue head: %+v", details.Head)
+	}
+
+	var inactive []string
+	getJson(t, h, "/frontier/inactive", &inactive)
+	if len(inactive) != 1 || inactive[0] != "b.com" {
+		t.Fatalf("Unexpected inactive queues: %v", inactive)
+	}
+
+	if code := getJson(t, h, "/frontier/queues/c.com", nil); code != http.StatusNotFound {
+		t.Fatalf("Unexpected status for a missing queue: %d", code)
+	}
+	if code := getJson(t, h, "/frontier/queues?limit=x", nil); code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status for an invalid limit: %d", code)
+	}
+	if code := getJson(t, h, "/frontier/queues/a.com?head=1001", nil); code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status for a head above the maximum: %d", code)
+	}
+}
@@ -1,56 +1,175 @@
 package frontier
 
 import (
-	"bufio"
 	"bytes"
+	"hash/fnv"
+	"maps"
 	"sync"
 
 	boom "github.com/tylertreat/BoomFilters"
 	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
 )
 
 type BloomStorage storage.Storage[*boom.ScalableBloomFilter]
 
+const bloomShards = 64
+
+type cachedBloom struct {
+	filter *boom.ScalableBloomFilter
+	dirty  bool
+}
+
+type bloomShard struct {
+	mu    sync.Mutex
+	cache *inmem.LruCache[*cachedBloom]
+	// evicted holds the dirty filters pushed out of the cache until they
+	// are written back.
+	evicted map[string]*boom.ScalableBloomFilter
+}
+
+// bloom keeps the seen urls of each host in a scalable bloom filter with a
+// false positive rate of 1%. Hosts are spread over shards with a lock and a
+// filter cache each. Changed filters are only written back to the storage by
+// flush, in one batch per shard.
 type bloom struct {
-	mu      sync.Mutex
 	storage BloomStorage
+	shards  [bloomShards]*bloomShard
 }
 
-func newBloom(storage BloomStorage) *bloom {
-	return &bloom{
+func newBloom(storage BloomStorage, cacheSize uint) *bloom {
+	b := &bloom{
 		storage: storage,
 	}
+
+	for i := range b.shards {
+		shard := &bloomShard{
+			cache:   inmem.NewLruCache[*cachedBloom](max(cacheSize/bloomShards, 1)),
+			evicted: make(map[string]*boom.ScalableBloomFilter),
+		}
+		shard.cache.SetOnEvict(func(k string, v *cachedBloom) {
+			if v.dirty {
+				shard.evicted[k] = v.filter
+			}
+		})
+		b.shards[i] = shard
+	}
+	return b
+}
+
+func (b *bloom) shard(key string) *bloomShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return b.shards[h.Sum32()%bloomShards]
 }
 
-func (b *bloom) addBloom(key string, entry []byte) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	bloom, err := b.storage.Get(key)
+// load returns the cached filter of the key, reading it from the storage or
+// creating an empty one if needed. It has to be called with the shard lock
+// held.
+func (b *bloom) load(shard *bloomShard, key string) (*cachedBloom, error) {
+	if cached, err := shard.cache.Get(key); err == nil {
+		return cached, nil
+	}
+
+	cached := &cachedBloom{}
+	if filter, ok := shard.evicted[key]; ok {
+		delete(shard.evicted, key)
+		cached.filter = filter
+		cached.dirty = true
+	} else {
+		filter, err := b.storage.Get(key)
+		if err != nil {
+			if err != storage.NoSuchKeyError {
+				return nil, err
+			}
+			filter = boom.NewDefaultScalableBloomFilter(0.01)
+		}
+		cached.filter = filter
+	}
+
+	return cached, shard.cache.Put(key, cached)
+}
+
+func (b *bloom) add(key string, entry []byte) error {
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	cached, err := b.load(shard, key)
 	if err != nil {
-		if err != storage.NoSuchKeyError {
+		return err
+	}
+	cached.filter.Add(entry)
+	cached.dirty = true
+	return nil
+}
+
+func (b *bloom) contains(key string, entry []byte) (bool, error) {
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	cached, err := b.load(shard, key)
+	if err != nil {
+		return false, err
+	}
+	return cached.filter.Test(entry), nil
+}
+
+// flush writes the changed filters back to the storage.
+func (b *bloom) flush() error {
+	for _, shard := range b.shards {
+		if err := b.flushShard(shard); err != nil {
 			return err
 		}
-		bloom = boom.NewDefaultScalableBloomFilter(0.01)
 	}
-	bloom.Add(entry)
-	return b.storage.Put(key, bloom)
+	return nil
 }
 
-func (b *bloom) checkBloom(key string, entry []byte) (bool, error) {
-	b.mu.Lock()
-	bloom, err := b.storage.Get(key)
-	b.mu.Unlock()
-	if err != nil {
-		if err != storage.NoSuchKeyError {
-			return false, err
+func (b *bloom) flushShard(shard *bloomShard) error {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	batch := maps.Clone(shard.evicted)
+	var dirty []*cachedBloom
+	shard.cache.Range(func(k string, v *cachedBloom) bool {
+		if v.dirty {
+			batch[k] = v.filter
+			dirty = append(dirty, v)
 		}
-		return false, nil
+		return true
+	})
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := storage.PutAll(b.storage, batch); err != nil {
+		return err
 	}
+	for _, cached := range dirty {
+		cached.dirty = false
+	}
+	clear(shard.evicted)
+	flushedFilters.Add(float64(len(batch)))
+	return nil
+}
+
+func (b *bloom) forget(key string) error {
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-	return bloom.Test(entry), nil
+	shard.cache.Delete(key)
+	delete(shard.evicted, key)
+
+	err := b.storage.Delete(key)
+	if err == storage.NoSuchKeyError {
+		return nil
+	}
+	return err
 }
 
-func (b *bloom) setBloom(key string, bloom []byte) error { //this library doesn't allow for merging two bloom filters
+func (b *bloom) restore(key string, bloom []byte) error { //this library doesn't allow for merging two bloom filters
 	r := bytes.NewReader(bloom)
 	bl := boom.NewDefaultScalableBloomFilter(0.1)
 	_, err := bl.ReadFrom(r)
@@ -58,29 +177,50 @@ func (b *bloom) setBloom(key string, bloom []byte) error { //this library doesn'
 		return err
 	}
 
-	b.mu.Lock()
-	err = b.storage.Put(key, bl)
-	b.mu.Unlock()
-	if err != nil {
-		return err
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.evicted, key)
+	if cached, err := shard.cache.Get(key); err == nil {
+		cached.filter = bl
+		cached.dirty = true
+		return nil
 	}
-	return nil
+	return shard.cache.Put(key, &cachedBloom{filter: bl, dirty: true})
 }
 
-func (b *bloom) getBloom(key string) ([]byte, error) {
-	b.mu.Lock()
-	bloom, err := b.storage.Get(key)
-	b.mu.Unlock()
-	if err != nil {
-		if err != storage.NoSuchKeyError {
-			return []byte{}, err
-		}
+func (b *bloom) export(key string) ([]byte, error) {
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-		bloom = boom.NewDefaultScalableBloomFilter(0.01)
+	cached, err := b.load(shard, key)
+	if err != nil {
+		return []byte{}, err
 	}
 
 	var buff bytes.Buffer
-	writer := bufio.NewWriter(&buff)
-	bloom.WriteTo(writer)
+	if _, err := cached.filter.WriteTo(&buff); err != nil {
+		return []byte{}, err
+	}
 	return buff.Bytes(), nil
 }
+
+func (b *bloom) stats(key string) (*BloomStats, error) {
+	shard := b.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	cached, err := b.load(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	bloom := cached.filter
+	return &BloomStats{
+		Capacity:  bloom.Capacity(),
+		Hashes:    bloom.K(),
+		FillRatio: bloom.FillRatio(),
+	}, nil
+}
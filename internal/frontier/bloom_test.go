@@ -0,0 +1,178 @@
+package frontier
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	boom "github.com/tylertreat/BoomFilters"
+	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+// codecStorage keeps filters serialized, like the RocksDB storage does.
+type codecStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	puts int
+}
+
+func newCodecStorage() *codecStorage {
+	return &codecStorage{data: make(map[string][]byte)}
+}
+
+func (s *codecStorage) Get(key string) (*boom.ScalableBloomFilter, error) {
+	s.mu.Lock()
+	data, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil, storage.NoSuchKeyError
+	}
+
+	filter := boom.NewDefaultScalableBloomFilter(0.01)
+	_, err := filter.ReadFrom(bytes.NewReader(data))
+	return filter, err
+}
+
+func (s *codecStorage) Put(key string, filter *boom.ScalableBloomFilter) error {
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.data[key] = buf.Bytes()
+	s.puts++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *codecStorage) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.data, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestBloomWriteBack(t *testing.T) {
+	backing := newCodecStorage()
+	b := newBloom(backing, bloomShards)
+
+	for i := 0; i < 1000; i++ {
+		host := fmt.Sprintf("%d.com", i%100)
+		if err := b.add(host, []byte(fmt.Sprintf("http://%s/%d", host, i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if backing.puts != 0 {
+		t.Fatalf("Filters written before a flush: %d", backing.puts)
+	}
+
+	// most filters were evicted from the cache but are still found
+	if seen, _ := b.contains("0.com", []byte("http://0.com/0")); !seen {
+		t.Fatalf("Url of an evicted filter was lost")
+	}
+
+	if err := b.flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(backing.data) != 100 {
+		t.Fatalf("Unexpected number of written filters. Have: %d, want: 100", len(backing.data))
+	}
+
+	puts := backing.puts
+	if err := b.flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if backing.puts != puts {
+		t.Fatalf("Clean filters were written again")
+	}
+
+	reloaded := newBloom(backing, bloomShards)
+	for _, u := range []string{"http://0.com/0", "http://99.com/999"} {
+		host := toId(mustParse(t, u))
+		if seen, _ := reloaded.contains(host, []byte(u)); !seen {
+			t.Fatalf("Url %s not found after a reload", u)
+		}
+	}
+
+	data, err := reloaded.export("5.com")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other := newBloom(newCodecStorage(), bloomShards)
+	if err := other.restore("5.com", data); err != nil {
+		t.Fatal(err.Error())
+	}
+	if seen, _ := other.contains("5.com", []byte("http://5.com/5")); !seen {
+		t.Fatalf("Restored filter lost its urls")
+	}
+
+	if err := reloaded.forget("5.com"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if seen, _ := reloaded.contains("5.com", []byte("http://5.com/5")); seen {
+		t.Fatalf("Url found after its host was forgotten")
+	}
+}
+
+// lockedBloom is the seen-set as it was before sharding: a single lock over
+// a sliding cache that writes a filter back on every eviction.
+type lockedBloom struct {
+	mu      sync.Mutex
+	storage BloomStorage
+}
+
+func (b *lockedBloom) add(key string, entry []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bloom, err := b.storage.Get(key)
+	if err != nil {
+		bloom = boom.NewDefaultScalableBloomFilter(0.01)
+	}
+	bloom.Add(entry)
+	return b.storage.Put(key, bloom)
+}
+
+func (b *lockedBloom) contains(key string, entry []byte) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bloom, err := b.storage.Get(key)
+	if err != nil {
+		return false, nil
+	}
+	return bloom.Test(entry), nil
+}
+
+// BenchmarkSeen checks and adds urls of 4096 hosts from parallel goroutines,
+// with room for 1024 filters in memory.
+func BenchmarkSeen(b *testing.B) {
+	const hosts = 4096
+	const cacheSize = 1024
+
+	run := func(b *testing.B, add func(string, []byte) error, contains func(string, []byte) (bool, error)) {
+		var n atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				i := n.Add(1)
+				host := fmt.Sprintf("%d.com", i*7919%hosts)
+				u := []byte(fmt.Sprintf("http://%s/%d", host, i))
+				if seen, _ := contains(host, u); !seen {
+					add(host, u)
+				}
+			}
+		})
+	}
+
+	b.Run("locked", func(b *testing.B) {
+		seen := &lockedBloom{storage: inmem.NewSlidingStorage[*boom.ScalableBloomFilter](newCodecStorage(), cacheSize)}
+		run(b, seen.add, seen.contains)
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		seen := newBloom(newCodecStorage(), cacheSize)
+		run(b, seen.add, seen.contains)
+	})
+}
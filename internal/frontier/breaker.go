@@ -0,0 +1,191 @@
+package frontier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+// FailureKind is a kind of fetch error that means the host itself is
+// unreachable.
+type FailureKind string
+
+const (
+	FailureDns     FailureKind = "dns"
+	FailureRefused FailureKind = "refused"
+	FailureTimeout FailureKind = "timeout"
+)
+
+// classifyFailure tells whether the error of a fetch counts against the
+// host, as opposed to e.g. a single broken page.
+func classifyFailure(err error) (FailureKind, bool) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return FailureDns, true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return FailureRefused, true
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout, true
+	}
+	return "", false
+}
+
+// BreakerPolicy decides when a failing host is cooled down and when it is
+// given up on. Failures are consecutive: a successful fetch resets them.
+type BreakerPolicy struct {
+	// CooldownAfter is the number of failures after which the host is no
+	// longer scheduled until its cooldown is over.
+	CooldownAfter uint32
+	// RetireAfter is the number of failures after which the queue of the
+	// host is retired and its urls are parked. Zero never retires.
+	RetireAfter uint32
+	// Cooldown is the first cooldown. It doubles with every further failure,
+	// up to MaxCooldown.
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+}
+
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		CooldownAfter: 3,
+		RetireAfter:   10,
+		Cooldown:      time.Minute,
+		MaxCooldown:   time.Hour,
+	}
+}
+
+func (p BreakerPolicy) cooldown(failures uint32) time.Duration {
+	if p.CooldownAfter == 0 || failures < p.CooldownAfter {
+		return 0
+	}
+
+	d := p.Cooldown
+	for i := p.CooldownAfter; i < failures && d < p.MaxCooldown; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxCooldown)
+}
+
+// HostHealth is the failure state of a host.
+type HostHealth struct {
+	Failures      uint32      `json:"failures"`
+	LastFailure   FailureKind `json:"last_failure"`
+	CooldownUntil time.Time   `json:"cooldown_until"`
+	Dead          bool        `json:"dead"`
+}
+
+type HealthStorage storage.Storage[HostHealth]
+
+// maxCachedHealth bounds the number of hosts whose health is kept in memory.
+// The least recently loaded hosts are read from the storage again.
+const maxCachedHealth = 100_000
+
+type breaker struct {
+	policy BreakerPolicy
+
+	mu      sync.Mutex
+	storage HealthStorage
+	hosts   *inmem.LruCache[HostHealth]
+}
+
+func newBreaker(policy BreakerPolicy, storage HealthStorage) *breaker {
+	return &breaker{
+		policy:  policy,
+		storage: storage,
+		hosts:   inmem.NewLruCache[HostHealth](maxCachedHealth),
+	}
+}
+
+func (b *breaker) get(host string) (HostHealth, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.load(host)
+}
+
+func (b *breaker) load(host string) (HostHealth, error) {
+	if health, err := b.hosts.Get(host); err == nil {
+		return health, nil
+	}
+
+	health, err := b.storage.Get(host)
+	if err != nil && err != storage.NoSuchKeyError {
+		return HostHealth{}, err
+	}
+	return health, b.hosts.Put(host, health)
+}
+
+// failure counts a failure of the host at now and returns its new state.
+func (b *breaker) failure(host string, kind FailureKind, now time.Time) (HostHealth, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health, err := b.load(host)
+	if err != nil {
+		return health, err
+	}
+
+	health.Failures++
+	health.LastFailure = kind
+	if cooldown := b.policy.cooldown(health.Failures); cooldown > 0 {
+		health.CooldownUntil = now.Add(cooldown)
+	}
+	health.Dead = b.policy.RetireAfter > 0 && health.Failures >= b.policy.RetireAfter
+
+	if err := b.storage.Put(host, health); err != nil {
+		return health, err
+	}
+	return health, b.hosts.Put(host, health)
+}
+
+// success resets the failures of the host.
+func (b *breaker) success(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health, err := b.load(host)
+	if err != nil || health.Failures == 0 {
+		return err
+	}
+
+	if err := b.storage.Delete(host); err != nil {
+		return err
+	}
+	return b.hosts.Put(host, HostHealth{})
+}
+
+// merge takes over the state of a host without failures of its own.
+func (b *breaker) merge(host string, health HostHealth) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, err := b.load(host)
+	if err != nil || current.Failures > 0 {
+		return err
+	}
+
+	if err := b.storage.Put(host, health); err != nil {
+		return err
+	}
+	return b.hosts.Put(host, health)
+}
+
+func (b *breaker) cooldownUntil(host string) time.Time {
+	health, _ := b.get(host)
+	return health.CooldownUntil
+}
+
+func (b *breaker) dead(host string) bool {
+	health, _ := b.get(host)
+	return health.Dead
+}
@@ -0,0 +1,90 @@
+package frontier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind FailureKind
+		ok   bool
+	}{
+		{&url.Error{Op: "Get", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, FailureDns, true},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, FailureRefused, true},
+		{&url.Error{Op: "Get", Err: context.DeadlineExceeded}, FailureTimeout, true},
+		{fmt.Errorf("fetch: %w", os.ErrDeadlineExceeded), FailureTimeout, true},
+		{errors.New("unexpected EOF"), "", false},
+	}
+
+	for _, test := range tests {
+		kind, ok := classifyFailure(test.err)
+		if kind != test.kind || ok != test.ok {
+			t.Errorf("Unexpected class of %q. Have: %q %t, want: %q %t", test.err, kind, ok, test.kind, test.ok)
+		}
+	}
+}
+
+func TestBreakerCooldown(t *testing.T) {
+	policy := BreakerPolicy{CooldownAfter: 2, Cooldown: time.Minute, MaxCooldown: 5 * time.Minute}
+
+	for failures, want := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if have := policy.cooldown(uint32(failures)); have != want {
+			t.Errorf("Unexpected cooldown after %d failures. Have: %s, want: %s", failures, have, want)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	healthStorage := inmem.NewInMemoryStorage[HostHealth]()
+	policy := BreakerPolicy{CooldownAfter: 2, RetireAfter: 3, Cooldown: time.Hour, MaxCooldown: time.Hour}
+	f := newTestFrontier(WithCircuitBreaker(policy), WithHealthStorage(healthStorage))
+
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2", "http://a.com/3", "http://a.com/4"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	timeout := &url.Error{Op: "Get", Err: context.DeadlineExceeded}
+	f.MarkFailed(mustParse(t, "http://a.com/0"), errors.New("unexpected EOF"))
+	f.MarkFailed(mustParse(t, "http://a.com/0"), timeout)
+	if f.getNextRequestTime("a.com").After(time.Now().Add(time.Minute)) {
+		t.Fatalf("Host put on cooldown before reaching the threshold")
+	}
+
+	f.MarkSuccessful(mustParse(t, "http://a.com/0"), UrlMeta{}, FetchInfo{})
+	f.MarkFailed(mustParse(t, "http://a.com/0"), timeout)
+	f.MarkFailed(mustParse(t, "http://a.com/0"), timeout)
+	if !f.getNextRequestTime("a.com").After(time.Now().Add(59 * time.Minute)) {
+		t.Fatalf("Host not put on cooldown")
+	}
+	if f.queueMap["a.com"].IsRetired() {
+		t.Fatalf("Queue retired before reaching the threshold")
+	}
+
+	f.MarkFailed(mustParse(t, "http://a.com/0"), timeout)
+	queue := f.queueMap["a.com"]
+	if !queue.IsRetired() || queue.Len() != 4 {
+		t.Fatalf("Dead host not retired with its urls parked. Retired: %t, urls: %d", queue.IsRetired(), queue.Len())
+	}
+	if stats := f.queueStats("a.com", queue); stats.State != QueueDead || stats.Failures != 3 || stats.LastFailure != "timeout" {
+		t.Fatalf("Unexpected stats of a dead host: %+v", stats)
+	}
+
+	restarted := newTestFrontier(WithCircuitBreaker(policy), WithHealthStorage(healthStorage))
+	restarted.Put(mustParse(t, "http://a.com/5"), UrlMeta{})
+	if !restarted.queueMap["a.com"].IsRetired() {
+		t.Fatalf("Dead host not retired after a restart")
+	}
+}
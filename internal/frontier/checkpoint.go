@@ -0,0 +1,194 @@
+package frontier
+
+import (
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+// SchedulingState is the scheduling state of a host queue, kept between runs
+// so that politeness and fairness continue where they left off.
+type SchedulingState struct {
+	Active bool `json:"active"`
+	// Paused is set on queues paused by an operator. Locks held while a
+	// host moves to another node are not kept.
+	Paused        bool   `json:"paused,omitempty"`
+	SessionBudget uint64 `json:"session_budget"`
+	// NextAccess is when the queue was scheduled. It is zero for queues that
+	// were not scheduled or were being crawled.
+	NextAccess   time.Time     `json:"next_access"`
+	ResponseTime time.Duration `json:"response_time,omitempty"`
+	CrawlDelay   time.Duration `json:"crawl_delay,omitempty"`
+	// InactiveRank is the position of an inactive queue in the order queues
+	// are woken in.
+	InactiveRank int `json:"inactive_rank,omitempty"`
+}
+
+// StateStorage keeps the scheduling state of the queues, by id.
+type StateStorage interface {
+	GetAll() (map[string]SchedulingState, error)
+	PutAll(map[string]SchedulingState) error
+}
+
+// Checkpoint saves the scheduling state of all queues to the state storage.
+func (f *BfFrontier) Checkpoint() error {
+	if f.opts.stateStorage == nil {
+		return nil
+	}
+
+	if err := f.opts.stateStorage.PutAll(f.schedulingStates()); err != nil {
+		return err
+	}
+	checkpoints.Inc()
+	return nil
+}
+
+func (f *BfFrontier) schedulingStates() map[string]SchedulingState {
+	f.iqMu.Lock()
+	var inactive []string
+	if peeker, ok := f.inactiveQueues.(storage.Peeker[string]); ok {
+		inactive, _ = peeker.PeekN(f.inactiveQueues.Len())
+	}
+	f.iqMu.Unlock()
+
+	f.block.L.Lock()
+	scheduled := maps.Clone(f.scheduled)
+	f.block.L.Unlock()
+
+	f.rtMu.Lock()
+	responseTime := maps.Clone(f.responseTime)
+	crawlDelay := maps.Clone(f.crawlDelay)
+	f.rtMu.Unlock()
+
+	f.qmMu.Lock()
+	queues := maps.Clone(f.queueMap)
+	f.qmMu.Unlock()
+
+	rank := make(map[string]int, len(inactive))
+	for i, id := range inactive {
+		if _, ok := rank[id]; !ok {
+			rank[id] = i
+		}
+	}
+
+	states := make(map[string]SchedulingState, len(queues))
+	for id, q := range queues {
+		state := SchedulingState{
+			Active:        q.IsActive(),
+			Paused:        q.IsPaused(),
+			SessionBudget: q.SessionBudget(),
+			NextAccess:    scheduled[id],
+			ResponseTime:  responseTime[id],
+			CrawlDelay:    crawlDelay[id],
+			InactiveRank:  len(inactive),
+		}
+		if r, ok := rank[id]; ok {
+			state.InactiveRank = r
+		}
+		states[id] = state
+	}
+	return states
+}
+
+// checkpoint saves the scheduling state periodically.
+func (f *BfFrontier) checkpoint() {
+	t := time.NewTicker(f.opts.checkpointInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			f.Checkpoint()
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// LoadQueues adds queues found in the queue storage. Queues with a saved
+// scheduling state get it back: active queues are scheduled first, at their
+// saved time, and inactive ones are woken in their saved order. Queues
+// without a state are added as new ones.
+func (f *BfFrontier) LoadQueues(queues map[string]storage.Queue[Url]) error {
+	states := make(map[string]SchedulingState)
+	if f.opts.stateStorage != nil {
+		var err error
+		if states, err = f.opts.stateStorage.GetAll(); err != nil {
+			return err
+		}
+	}
+
+	var active, inactive []string
+	for id := range queues {
+		state, ok := states[id]
+		switch {
+		case !ok:
+		case state.Active:
+			active = append(active, id)
+		default:
+			inactive = append(inactive, id)
+		}
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return states[active[i]].NextAccess.Before(states[active[j]].NextAccess)
+	})
+	sort.Slice(inactive, func(i, j int) bool {
+		return states[inactive[i]].InactiveRank < states[inactive[j]].InactiveRank
+	})
+
+	for _, id := range append(active, inactive...) {
+		f.restoreQueue(id, queues[id], states[id])
+	}
+	restoredQueues.Add(float64(len(active) + len(inactive)))
+
+	for id, queue := range queues {
+		if _, ok := states[id]; !ok {
+			f.addNewQueue(id, queue)
+		}
+	}
+	return nil
+}
+
+func (f *BfFrontier) restoreQueue(id string, q storage.Queue[Url], state SchedulingState) {
+	f.rtMu.Lock()
+	if state.ResponseTime > 0 {
+		f.responseTime[id] = state.ResponseTime
+	}
+	if state.CrawlDelay > 0 {
+		f.crawlDelay[id] = state.CrawlDelay
+	}
+	f.rtMu.Unlock()
+
+	if f.isRetired(id) {
+		f.addNewQueue(id, q)
+		return
+	}
+
+	budget := state.SessionBudget
+	if budget == 0 {
+		budget = f.calculateSessionBudget(id)
+	}
+
+	active := state.Active && f.inWindow(id) && f.incActiveCountIfCan()
+	queue := NewFrontierQueue(q, active, budget)
+	if state.Paused {
+		queue.Pause()
+	}
+
+	f.qmMu.Lock()
+	f.queueMap[id] = queue
+	f.qmMu.Unlock()
+
+	if !active {
+		f.enqueueInactiveId(id)
+		return
+	}
+
+	at := state.NextAccess
+	if at.IsZero() {
+		at = f.now()
+	}
+	f.setNextQueue(id, at)
+}
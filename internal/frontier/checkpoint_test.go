@@ -0,0 +1,71 @@
+package frontier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func TestCheckpoint(t *testing.T) {
+	states := inmem.NewInMemoryStorage[SchedulingState]()
+	f := newTestFrontier(WithMaxActiveQueues(2), WithStateStorage(states))
+
+	for _, raw := range []string{"http://a.com/1", "http://b.com/1", "http://c.com/1", "http://d.com/1", "http://d.com/2"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	f.SetCrawlDelay(mustParse(t, "http://d.com/"), 5*time.Second)
+	if err := f.PauseHost("d.com"); err != nil {
+		t.Fatal(err.Error())
+	}
+	f.setQueueLock("c.com", true)
+
+	if err := f.Checkpoint(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	queues := make(map[string]storage.Queue[Url])
+	for id, q := range f.queueMap {
+		queues[id] = q.queue
+	}
+	inactive, _ := f.InactiveQueues(10)
+
+	restored := newTestFrontier(WithMaxActiveQueues(2), WithStateStorage(states))
+	if err := restored.LoadQueues(queues); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for id, q := range f.queueMap {
+		r := restored.queueMap[id]
+		if r.IsActive() != q.IsActive() || r.IsPaused() != q.IsPaused() || r.SessionBudget() != q.SessionBudget() {
+			t.Fatalf("Unexpected state of %s. Have: %+v, want: %+v", id, f.queueStats(id, r), f.queueStats(id, q))
+		}
+		if have, want := restored.scheduled[id], f.scheduled[id]; !have.Equal(want) {
+			t.Fatalf("Unexpected next access of %s. Have: %s, want: %s", id, have, want)
+		}
+	}
+
+	if !restored.queueMap["d.com"].IsPaused() {
+		t.Fatalf("Paused host was resumed")
+	}
+	if restored.queueMap["c.com"].IsLocked() {
+		t.Fatalf("Key lock was kept")
+	}
+
+	restoredInactive, _ := restored.InactiveQueues(10)
+	if len(restoredInactive) != len(inactive) {
+		t.Fatalf("Unexpected inactive queues. Have: %v, want: %v", restoredInactive, inactive)
+	}
+	for i := range inactive {
+		if restoredInactive[i] != inactive[i] {
+			t.Fatalf("Inactive order was not kept. Have: %v, want: %v", restoredInactive, inactive)
+		}
+	}
+
+	if delay := restored.crawlDelay["d.com"]; delay != 5*time.Second {
+		t.Fatalf("Unexpected crawl delay. Have: %s, want: 5s", delay)
+	}
+}
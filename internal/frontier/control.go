@@ -0,0 +1,164 @@
+package frontier
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Controller changes a running crawl.
+type Controller interface {
+	Put(*url.URL, UrlMeta) error
+	PauseHost(host string) error
+	ResumeHost(host string) error
+	PurgeHost(host string) (int, error)
+	Pause() error
+	Resume() error
+}
+
+// maxUploadSize bounds the body of a url upload.
+const maxUploadSize = 32 << 20
+
+type injectRequest struct {
+	Urls     []string `json:"urls"`
+	Priority uint32   `json:"priority"`
+	MaxDepth uint32   `json:"max_depth"`
+}
+
+type injectResponse struct {
+	Submitted int      `json:"submitted"`
+	Invalid   []string `json:"invalid,omitempty"`
+}
+
+// NewControlHandler returns an HTTP API to control a running crawl. Every
+// request has to carry the token as "Authorization: Bearer <token>".
+//
+//	POST /control/urls                 put urls, as JSON or one per line
+//	POST /control/pause                stop handing out urls
+//	POST /control/resume               hand out urls again
+//	POST /control/hosts/{host}/pause   stop crawling a host
+//	POST /control/hosts/{host}/resume  resume crawling a host
+//	POST /control/hosts/{host}/purge   drop the queue and seen urls of a host
+func NewControlHandler(c Controller, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /control/urls", func(w http.ResponseWriter, r *http.Request) {
+		req, err := readInjectRequest(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res := injectResponse{}
+		meta := UrlMeta{Priority: req.Priority, MaxDepth: req.MaxDepth}
+		for _, raw := range req.Urls {
+			u, err := url.Parse(raw)
+			if err != nil || u.Hostname() == "" {
+				res.Invalid = append(res.Invalid, raw)
+				continue
+			}
+
+			if err := c.Put(u, meta); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			res.Submitted++
+		}
+		writeJson(w, http.StatusOK, res)
+	})
+
+	mux.HandleFunc("POST /control/pause", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Pause(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]bool{"paused": true})
+	})
+
+	mux.HandleFunc("POST /control/resume", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Resume(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]bool{"paused": false})
+	})
+
+	mux.HandleFunc("POST /control/hosts/{host}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		host := strings.ToLower(r.PathValue("host"))
+
+		var err error
+		res := map[string]any{"host": host}
+		switch action := r.PathValue("action"); action {
+		case "pause":
+			err = c.PauseHost(host)
+		case "resume":
+			err = c.ResumeHost(host)
+		case "purge":
+			var n int
+			n, err = c.PurgeHost(host)
+			res["purged"] = n
+		default:
+			writeError(w, http.StatusNotFound, errors.New("Unknown action: "+action))
+			return
+		}
+
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(w, http.StatusOK, res)
+	})
+
+	return authorize(token, mux)
+}
+
+func authorize(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readInjectRequest reads a JSON body, or a plain text one with a url per
+// line. Plain text uploads take their priority and depth limit from the
+// query.
+func readInjectRequest(r *http.Request) (injectRequest, error) {
+	body := io.LimitReader(r.Body, maxUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var req injectRequest
+		err := json.NewDecoder(body).Decode(&req)
+		return req, err
+	}
+
+	priority, err := intParam(r, "priority", 0)
+	if err != nil {
+		return injectRequest{}, err
+	}
+	maxDepth, err := intParam(r, "max_depth", 0)
+	if err != nil {
+		return injectRequest{}, err
+	}
+
+	req := injectRequest{Priority: uint32(priority), MaxDepth: uint32(maxDepth)}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		req.Urls = append(req.Urls, line)
+	}
+	return req, scanner.Err()
+}
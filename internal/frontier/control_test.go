@@ -0,0 +1,101 @@
+package frontier
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postControl(h http.Handler, path string, contentType string, body string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestControlApi(t *testing.T) {
+	f := newTestFrontier()
+	h := NewControlHandler(f, "secret")
+
+	if rec := postControl(h, "/control/pause", "", "", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status for a wrong token: %d", rec.Code)
+	}
+
+	rec := postControl(h, "/control/urls?priority=3", "text/plain", "http://a.com/1\n# comment\nhttp://a.com/2\n:bad\n", "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"submitted":2`) {
+		t.Fatalf("Unexpected text upload response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = postControl(h, "/control/urls", "application/json", `{"urls": ["http://b.com/1"], "max_depth": 2}`, "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"submitted":1`) {
+		t.Fatalf("Unexpected json upload response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	head, err := f.queueMap["a.com"].Head(2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(head) != 2 || head[0].Priority != 3 {
+		t.Fatalf("Unexpected injected urls: %+v", head)
+	}
+	if f.queueMap["b.com"].Len() != 1 {
+		t.Fatalf("Json upload was not queued")
+	}
+
+	postControl(h, "/control/hosts/A.com/pause", "", "", "secret")
+	if !f.queueMap["a.com"].IsPaused() {
+		t.Fatalf("Host was not paused")
+	}
+	f.setQueueLock("a.com", true)
+	f.setQueueLock("a.com", false)
+	if !f.queueMap["a.com"].IsPaused() {
+		t.Fatalf("Releasing the key lock resumed a paused host")
+	}
+	postControl(h, "/control/hosts/a.com/resume", "", "", "secret")
+	if f.queueMap["a.com"].IsPaused() {
+		t.Fatalf("Host was not resumed")
+	}
+
+	if err := f.MarkProcessed(mustParse(t, "http://a.com/1")); err != nil {
+		t.Fatal(err.Error())
+	}
+	rec = postControl(h, "/control/hosts/a.com/purge", "", "", "secret")
+	if !strings.Contains(rec.Body.String(), `"purged":2`) || f.queueMap["a.com"].Len() != 0 {
+		t.Fatalf("Unexpected purge response: %s", rec.Body.String())
+	}
+	if seen, _ := f.seen.contains("a.com", []byte("http://a.com/1")); seen {
+		t.Fatalf("Seen urls of a purged host were kept")
+	}
+
+	postControl(h, "/control/pause", "", "", "secret")
+	if !f.Paused() {
+		t.Fatalf("Crawl was not paused")
+	}
+	postControl(h, "/control/resume", "", "", "secret")
+	if f.Paused() {
+		t.Fatalf("Crawl was not resumed")
+	}
+}
+
+type unreachableController struct {
+	*BfFrontier
+}
+
+func (c unreachableController) Pause() error {
+	return errors.New("Node unreachable")
+}
+
+func TestControlPauseError(t *testing.T) {
+	h := NewControlHandler(unreachableController{newTestFrontier()}, "secret")
+
+	rec := postControl(h, "/control/pause", "", "", "secret")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "Node unreachable") {
+		t.Fatalf("Unexpected pause response: %d %s", rec.Code, rec.Body.String())
+	}
+}
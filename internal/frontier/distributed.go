@@ -23,6 +23,8 @@ const (
 	URL_FOUND   = "dispatcher.urlFound"
 	KEYS_LOCK   = "dispatcher.keysLock"
 	LOCK_NOTIFY = "dispatcher.lockNotify"
+	HOST_CMD    = "dispatcher.hostCommand"
+	CRAWL_CMD   = "dispatcher.crawlCommand"
 )
 
 type distributedOptions struct {
@@ -53,8 +55,10 @@ type DistributedFrontier struct {
 
 	frontier *BfFrontier
 
-	batches map[string][]string
+	batches map[string][]*pb.UrlEntry
 	batchMu sync.Mutex
+
+	stopLoops context.CancelFunc
 }
 
 func NewDistributed(logger *zap.Logger, peer *p2p.Peer, frontier *BfFrontier, dht *dht.DHT, opts ...DistributedOption) (*DistributedFrontier, error) {
@@ -72,7 +76,7 @@ func NewDistributed(logger *zap.Logger, peer *p2p.Peer, frontier *BfFrontier, dh
 		peer:     peer,
 		dht:      dht,
 		frontier: frontier,
-		batches:  make(map[string][]string),
+		batches:  make(map[string][]*pb.UrlEntry),
 
 		opts: *defaultOpts,
 	}
@@ -80,8 +84,12 @@ func NewDistributed(logger *zap.Logger, peer *p2p.Peer, frontier *BfFrontier, dh
 	peer.AddRequestHandler(URL_FOUND, d.urlFoundHandler)
 	peer.AddRequestHandler(KEYS_LOCK, d.keysLockHandler)
 	peer.AddStreamHandler(LOCK_NOTIFY, d.keyLockNotifyHandler)
+	peer.AddRequestHandler(HOST_CMD, d.hostCommandHandler)
+	peer.AddRequestHandler(CRAWL_CMD, d.crawlCommandHandler)
 
-	go d.dispatcherLoop(context.Background())
+	ctx, stopLoops := context.WithCancel(context.Background())
+	d.stopLoops = stopLoops
+	go d.dispatcherLoop(ctx)
 	return d, nil
 }
 
@@ -89,35 +97,202 @@ func (d *DistributedFrontier) Bootstrap(addr string) error {
 	return d.dht.Join(addr)
 }
 
-func (d *DistributedFrontier) Get() (*url.URL, time.Time, error) {
-	return d.frontier.Get()
+func (d *DistributedFrontier) Get(ctx context.Context) (*url.URL, UrlMeta, time.Time, error) {
+	return d.frontier.Get(ctx)
+}
+
+// Stop stops exchanging urls and keys with other nodes and waits for their
+// requests being handled. It has to be called before the storages of the
+// local frontier are closed.
+func (d *DistributedFrontier) Stop() error {
+	d.stopLoops()
+	return d.peer.Close()
+}
+
+// Close stops exchanging urls with other nodes and closes the local frontier.
+func (d *DistributedFrontier) Close() error {
+	if err := d.Stop(); err != nil {
+		return err
+	}
+	return d.frontier.Close()
+}
+
+func (d *DistributedFrontier) MarkSuccessful(u *url.URL, meta UrlMeta, info FetchInfo) error {
+	return d.frontier.MarkSuccessful(u, meta, info)
 }
 
-func (d *DistributedFrontier) MarkSuccessful(u *url.URL, ttr time.Duration) error {
-	return d.frontier.MarkSuccessful(u, ttr)
+func (d *DistributedFrontier) MarkFailed(u *url.URL, err error) error {
+	return d.frontier.MarkFailed(u, err)
 }
 
-func (d *DistributedFrontier) MarkFailed(u *url.URL) error {
-	return d.frontier.MarkFailed(u)
+func (d *DistributedFrontier) MarkRetry(u *url.URL, meta UrlMeta, after time.Duration) error {
+	return d.frontier.MarkRetry(u, meta, after)
 }
 
 func (d *DistributedFrontier) MarkProcessed(u *url.URL) error {
 	return d.frontier.MarkProcessed(u)
 }
 
-func (d *DistributedFrontier) Put(u *url.URL) error {
+func (d *DistributedFrontier) Put(u *url.URL, meta UrlMeta) error {
+	if !d.frontier.accepts(u, meta) {
+		return nil
+	}
+
 	succ, err := d.dht.FindSuccessor(d.dht.MakeKey([]byte(toId(u))))
 	if err != nil {
 		return err
 	}
 
 	if succ.Addr.String() == d.peer.GetAddr() {
-		return d.frontier.Put(u)
+		return d.frontier.enqueue(u, meta)
 	} else {
-		return d.createBatch(succ.Addr.String(), u)
+		return d.createBatch(succ.Addr.String(), u, meta)
 	}
 }
 
+// PauseHost pauses the host on the node that owns it.
+func (d *DistributedFrontier) PauseHost(host string) error {
+	_, err := d.hostCommand(host, "pause")
+	return err
+}
+
+// ResumeHost resumes the host on the node that owns it.
+func (d *DistributedFrontier) ResumeHost(host string) error {
+	_, err := d.hostCommand(host, "resume")
+	return err
+}
+
+// PurgeHost purges the host on the node that owns it.
+func (d *DistributedFrontier) PurgeHost(host string) (int, error) {
+	return d.hostCommand(host, "purge")
+}
+
+// Pause pauses every node in the ring.
+func (d *DistributedFrontier) Pause() error {
+	return d.crawlCommand("pause")
+}
+
+// Resume resumes every node in the ring.
+func (d *DistributedFrontier) Resume() error {
+	return d.crawlCommand("resume")
+}
+
+// crawlCommand applies the action on every node in the ring. Nodes that
+// can't be reached don't stop it from reaching the others.
+func (d *DistributedFrontier) crawlCommand(action string) error {
+	nodes, err := d.dht.Nodes()
+	if err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(&pb.CrawlCommand{Action: action})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		if node == d.peer.GetAddr() {
+			if err := d.applyCrawlCommand(action); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		res, err := p2p.NewRequestWriter(d.peer, node).Request(&p2p.Request{
+			Scope:   CRAWL_CMD,
+			Payload: data,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node, err))
+			continue
+		}
+		if res.IsError {
+			errs = append(errs, errors.New(fmt.Sprintf("Remote node error: %s: %s", node, string(res.Payload))))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *DistributedFrontier) applyCrawlCommand(action string) error {
+	d.logger.Infow("Crawl command", "action", action)
+	switch action {
+	case "pause":
+		return d.frontier.Pause()
+	case "resume":
+		return d.frontier.Resume()
+	default:
+		return errors.New(fmt.Sprintf("Unknown crawl command: %s", action))
+	}
+}
+
+func (d *DistributedFrontier) crawlCommandHandler(ctx p2p.Context, data []byte, rw *p2p.ResponseWriter) {
+	cmd := &pb.CrawlCommand{}
+	if err := proto.Unmarshal(data, cmd); err != nil {
+		rw.Response(false, []byte(err.Error()))
+		return
+	}
+
+	if err := d.applyCrawlCommand(cmd.Action); err != nil {
+		rw.Response(false, []byte(err.Error()))
+		return
+	}
+	rw.Response(true, []byte{})
+}
+
+func (d *DistributedFrontier) hostCommand(host string, action string) (int, error) {
+	succ, err := d.dht.FindSuccessor(d.dht.MakeKey([]byte(host)))
+	if err != nil {
+		return 0, err
+	}
+
+	if succ.Addr.String() == d.peer.GetAddr() {
+		return d.applyHostCommand(host, action)
+	}
+
+	res := &pb.HostCommandResult{}
+	err = p2p.NewRequestWriter(d.peer, succ.Addr.String()).RequestProto(HOST_CMD, &pb.HostCommand{
+		Host:   host,
+		Action: action,
+	}, res)
+	return int(res.Purged), err
+}
+
+func (d *DistributedFrontier) applyHostCommand(host string, action string) (int, error) {
+	d.logger.Infow("Host command", "host", host, "action", action)
+	switch action {
+	case "pause":
+		return 0, d.frontier.PauseHost(host)
+	case "resume":
+		return 0, d.frontier.ResumeHost(host)
+	case "purge":
+		return d.frontier.PurgeHost(host)
+	default:
+		return 0, errors.New(fmt.Sprintf("Unknown host command: %s", action))
+	}
+}
+
+func (d *DistributedFrontier) hostCommandHandler(ctx p2p.Context, data []byte, rw *p2p.ResponseWriter) {
+	cmd := &pb.HostCommand{}
+	if err := proto.Unmarshal(data, cmd); err != nil {
+		rw.Response(false, []byte(err.Error()))
+		return
+	}
+
+	purged, err := d.applyHostCommand(cmd.Host, cmd.Action)
+	if err != nil {
+		rw.Response(false, []byte(err.Error()))
+		return
+	}
+
+	res, err := proto.Marshal(&pb.HostCommandResult{Purged: uint64(purged)})
+	if err != nil {
+		rw.Response(false, []byte(err.Error()))
+		return
+	}
+	rw.Response(true, res)
+}
+
 func (d *DistributedFrontier) checkKeys() {
 	repartitioned := make(map[string][]string)
 	d.frontier.qmMu.Lock()
@@ -165,7 +340,7 @@ func (d *DistributedFrontier) checkKeys() {
 
 func (d *DistributedFrontier) sendKeyNotify(conn net.Conn, key string) error {
 	<-d.frontier.NotifyOnEnd(key)
-	bloom, err := d.frontier.bloom.getBloom(key)
+	bloom, err := d.frontier.seen.export(key)
 	if err != nil {
 		return err
 	}
@@ -206,7 +381,7 @@ func (d *DistributedFrontier) keyLockNotifyHandler(ctx p2p.Context, data chan []
 
 		d.logger.Infof("Unlocking key: %s", notif.Key)
 		d.frontier.setQueueLock(notif.Key, false)
-		d.frontier.bloom.setBloom(notif.Key, notif.Bloom)
+		d.frontier.seen.restore(notif.Key, notif.Bloom)
 	}
 }
 
@@ -257,29 +432,59 @@ func (d *DistributedFrontier) urlFoundHandler(ctx p2p.Context, data []byte, rw *
 		return
 	}
 
-	for _, e := range batch.Url {
+	for _, e := range batch.Url { //nodes that don't send url metadata yet
 		url, err := url.Parse(e)
 		if err != nil {
 			continue
 		}
 
-		d.frontier.Put(url)
+		d.frontier.Put(url, UrlMeta{})
+	}
+
+	for _, e := range batch.Entries { //already filtered by the sender
+		url, err := url.Parse(e.Url)
+		if err != nil {
+			continue
+		}
+
+		meta := UrlMeta{
+			Depth:           e.Depth,
+			MaxDepth:        e.MaxDepth,
+			Priority:        e.Priority,
+			Sitemap:         e.Sitemap,
+			SitemapPriority: e.SitemapPriority,
+		}
+		if e.LastMod != 0 {
+			meta.LastMod = time.Unix(e.LastMod, 0).UTC()
+		}
+		d.frontier.enqueue(url, meta)
 	}
 
 	rw.Response(true, []byte{})
 }
 
-func (d *DistributedFrontier) createBatch(node string, u *url.URL) error {
+func (d *DistributedFrontier) createBatch(node string, u *url.URL, meta UrlMeta) error {
 	d.batchMu.Lock()
 	defer d.batchMu.Unlock()
 
 	batch, ok := d.batches[node]
 
 	if !ok {
-		batch = make([]string, 0)
+		batch = make([]*pb.UrlEntry, 0)
 	}
 
-	batch = append(batch, u.String())
+	entry := &pb.UrlEntry{
+		Url:             u.String(),
+		Depth:           meta.Depth,
+		MaxDepth:        meta.MaxDepth,
+		Priority:        meta.Priority,
+		Sitemap:         meta.Sitemap,
+		SitemapPriority: meta.SitemapPriority,
+	}
+	if !meta.LastMod.IsZero() {
+		entry.LastMod = meta.LastMod.Unix()
+	}
+	batch = append(batch, entry)
 	d.batches[node] = batch
 	return nil
 }
@@ -292,7 +497,7 @@ func (d *DistributedFrontier) dispatcherLoop(ctx context.Context) {
 			case <-t:
 				go d.sendBatches(ctx)
 			case <-ctx.Done():
-				break
+				return
 			}
 		}
 	}()
@@ -304,7 +509,7 @@ func (d *DistributedFrontier) dispatcherLoop(ctx context.Context) {
 			case <-t:
 				d.checkKeys()
 			case <-ctx.Done():
-				break
+				return
 			}
 		}
 	}()
@@ -323,13 +528,13 @@ func (d *DistributedFrontier) sendBatches(ctx context.Context) {
 			continue
 		}
 
-		d.batches[k] = []string{}
+		d.batches[k] = []*pb.UrlEntry{}
 	}
 }
 
-func (d *DistributedFrontier) writeBatch(ctx context.Context, node string, scope string, messages []string) error {
+func (d *DistributedFrontier) writeBatch(ctx context.Context, node string, scope string, entries []*pb.UrlEntry) error {
 	batch := &pb.UrlBatch{
-		Url: messages,
+		Entries: entries,
 	}
 
 	batchBytes, err := proto.Marshal(batch)
@@ -0,0 +1,509 @@
+package frontier
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/xunterr/aracno/internal/hostname"
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+const (
+	exportFormat = "aracno-frontier"
+	// exportVersion 2 added recrawl records and urls in flight.
+	exportVersion = 2
+)
+
+const (
+	seenBloom = "bloom"
+	seenExact = "exact"
+)
+
+// exportHeader is the first line of an export.
+type exportHeader struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	SeenMode string    `json:"seen_mode"`
+}
+
+// exportRecord is a line of an export after the header. Exactly one of its
+// fields is set. The queued urls of a host follow the host; urls that were in
+// flight follow all hosts.
+type exportRecord struct {
+	Host    *exportedHost    `json:"host,omitempty"`
+	Url     *exportedUrl     `json:"url,omitempty"`
+	Domain  *exportedDomain  `json:"domain,omitempty"`
+	Recrawl *exportedRecrawl `json:"recrawl,omitempty"`
+}
+
+type exportedHost struct {
+	Id     string          `json:"id"`
+	State  SchedulingState `json:"state"`
+	Quota  QuotaStats      `json:"quota"`
+	Health *HostHealth     `json:"health,omitempty"`
+	// Seen is the seen-set of the host, in the seen mode of the header.
+	Seen []byte `json:"seen,omitempty"`
+}
+
+type exportedUrl struct {
+	Host            string `json:"host"`
+	Url             string `json:"url"`
+	Depth           uint32 `json:"depth"`
+	MaxDepth        uint32 `json:"max_depth,omitempty"`
+	Priority        uint32 `json:"priority,omitempty"`
+	Inlinks         uint32 `json:"inlinks,omitempty"`
+	Revisit         bool   `json:"revisit,omitempty"`
+	Sitemap         bool   `json:"sitemap,omitempty"`
+	SitemapPriority uint32 `json:"sitemap_priority,omitempty"`
+	// LastMod is the sitemap lastmod of the url, if any.
+	LastMod *time.Time `json:"last_mod,omitempty"`
+}
+
+type exportedDomain struct {
+	Domain string     `json:"domain"`
+	Quota  QuotaStats `json:"quota"`
+}
+
+// exportedRecrawl is what the recrawl schedule knows about a url: how often
+// it changes and, if a revisit is scheduled, when and with which metadata.
+type exportedRecrawl struct {
+	Url             string        `json:"url"`
+	State           *RecrawlState `json:"state,omitempty"`
+	Due             *time.Time    `json:"due,omitempty"`
+	Depth           uint32        `json:"depth,omitempty"`
+	MaxDepth        uint32        `json:"max_depth,omitempty"`
+	Priority        uint32        `json:"priority,omitempty"`
+	Sitemap         bool          `json:"sitemap,omitempty"`
+	SitemapPriority uint32        `json:"sitemap_priority,omitempty"`
+}
+
+// TransferStats counts what an export or an import moved.
+type TransferStats struct {
+	Hosts int
+	Urls  int
+	// InFlight is the number of urls that were handed out and not
+	// acknowledged. They are exported as queued urls.
+	InFlight int
+	// Recrawl is the number of urls with a recrawl state or a scheduled
+	// revisit.
+	Recrawl int
+	// SkippedRecrawl is the number of imported recrawl records that were not
+	// taken, because recrawl is disabled or the url is already known here.
+	SkippedRecrawl int
+	// SkippedSeen is the number of imported hosts whose seen-set was not
+	// taken, because it can't be converted to the local seen mode or the
+	// host has its own bloom filter.
+	SkippedSeen int
+}
+
+func (f *BfFrontier) seenMode() string {
+	if _, ok := f.seen.(*exactSeen); ok {
+		return seenExact
+	}
+	return seenBloom
+}
+
+// Export writes the whole frontier to w as gzip-compressed JSON lines: a
+// versioned header, then every host with its scheduling state, stats and
+// seen-set followed by its queued urls, the urls in flight, the quota stats
+// of domains and, with recrawl, the recrawl state and schedule of urls.
+func (f *BfFrontier) Export(w io.Writer) (TransferStats, error) {
+	var stats TransferStats
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+
+	err := enc.Encode(exportHeader{
+		Format:   exportFormat,
+		Version:  exportVersion,
+		Created:  time.Now().UTC(),
+		SeenMode: f.seenMode(),
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	states := f.schedulingStates()
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	domains := make(map[string]bool)
+	for _, id := range ids {
+		n, err := f.exportHost(enc, id, states[id])
+		if err != nil {
+			return stats, fmt.Errorf("Failed to export %s: %w", id, err)
+		}
+		stats.Hosts++
+		stats.Urls += n
+		domains[hostname.RegisteredDomain(id)] = true
+	}
+
+	inflight, err := f.leases.storage.GetAll()
+	if err != nil {
+		return stats, err
+	}
+	for _, lease := range inflight {
+		if err := enc.Encode(exportRecord{Url: newExportedUrl(lease.Queue, lease.Url, lease.UrlMeta)}); err != nil {
+			return stats, err
+		}
+		stats.Urls++
+		stats.InFlight++
+	}
+
+	for domain := range domains {
+		quota, err := f.quotas.get(domainQuotaKey(domain))
+		if err != nil {
+			return stats, err
+		}
+		if quota == (QuotaStats{}) {
+			continue
+		}
+		if err := enc.Encode(exportRecord{Domain: &exportedDomain{Domain: domain, Quota: quota}}); err != nil {
+			return stats, err
+		}
+	}
+
+	if f.recrawl != nil {
+		if stats.Recrawl, err = f.exportRecrawl(enc); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, zw.Close()
+}
+
+func newExportedUrl(host string, u string, meta UrlMeta) *exportedUrl {
+	rec := &exportedUrl{
+		Host:            host,
+		Url:             u,
+		Depth:           meta.Depth,
+		MaxDepth:        meta.MaxDepth,
+		Priority:        meta.Priority,
+		Inlinks:         meta.Inlinks,
+		Revisit:         meta.Revisit,
+		Sitemap:         meta.Sitemap,
+		SitemapPriority: meta.SitemapPriority,
+	}
+	if !meta.LastMod.IsZero() {
+		lastMod := meta.LastMod
+		rec.LastMod = &lastMod
+	}
+	return rec
+}
+
+type recrawlStateLister interface {
+	GetAll() (map[string]RecrawlState, error)
+}
+
+func (f *BfFrontier) exportRecrawl(enc *json.Encoder) (int, error) {
+	lister, ok := f.recrawl.states.(recrawlStateLister)
+	if !ok {
+		return 0, errors.New("Recrawl states can't be listed")
+	}
+	peeker, ok := f.recrawl.schedule.(storage.Peeker[ScheduledUrl])
+	if !ok {
+		return 0, errors.New("Recrawl schedule can't be listed")
+	}
+
+	f.recrawl.mu.Lock()
+	states, err := lister.GetAll()
+	var scheduled []ScheduledUrl
+	if err == nil {
+		scheduled, err = peeker.PeekN(f.recrawl.schedule.Len())
+	}
+	f.recrawl.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	records := make(map[string]*exportedRecrawl, len(states))
+	record := func(u string) *exportedRecrawl {
+		if _, ok := records[u]; !ok {
+			records[u] = &exportedRecrawl{Url: u}
+		}
+		return records[u]
+	}
+	for u, state := range states {
+		record(u).State = &state
+	}
+	for _, s := range scheduled {
+		rec := record(s.Url)
+		if rec.Due != nil && !s.At.Before(*rec.Due) {
+			continue
+		}
+		at := s.At
+		rec.Due = &at
+		rec.Depth = s.Depth
+		rec.MaxDepth = s.MaxDepth
+		rec.Priority = s.Priority
+		rec.Sitemap = s.Sitemap
+		rec.SitemapPriority = s.SitemapPriority
+	}
+
+	urls := make([]string, 0, len(records))
+	for u := range records {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		if err := enc.Encode(exportRecord{Recrawl: records[u]}); err != nil {
+			return 0, err
+		}
+	}
+	return len(urls), nil
+}
+
+func (f *BfFrontier) exportHost(enc *json.Encoder, id string, state SchedulingState) (int, error) {
+	f.qmMu.Lock()
+	queue := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	peeker, ok := queue.queue.(storage.Peeker[Url])
+	if !ok {
+		return 0, errors.New("Queue can't be listed")
+	}
+	urls, err := peeker.PeekN(queue.Len())
+	if err != nil {
+		return 0, err
+	}
+
+	host := exportedHost{Id: id, State: state}
+	if host.Quota, err = f.quotas.get(hostQuotaKey(id)); err != nil {
+		return 0, err
+	}
+	if f.breaker != nil {
+		health, err := f.breaker.get(id)
+		if err != nil {
+			return 0, err
+		}
+		if health.Failures > 0 {
+			host.Health = &health
+		}
+	}
+	if host.Seen, err = f.seen.export(id); err != nil {
+		return 0, err
+	}
+
+	if err := enc.Encode(exportRecord{Host: &host}); err != nil {
+		return 0, err
+	}
+	for _, u := range urls {
+		if err := enc.Encode(exportRecord{Url: newExportedUrl(id, u.Url, u.UrlMeta)}); err != nil {
+			return 0, err
+		}
+	}
+	return len(urls), nil
+}
+
+// Import merges an export into the frontier. New hosts get their scheduling
+// state, except for pauses; hosts that already exist keep theirs and only
+// take the response time and crawl delay they don't know yet. Quota stats
+// are added up, and urls already seen here are not queued again, except for
+// revisits. Recrawl records are only taken for urls not known here, and
+// only with recrawl enabled.
+//
+// Exact seen-sets are merged. A bloom filter is only taken by a host that
+// has none, and is kept as the legacy filter in exact mode. Exact seen-sets
+// can't be imported in bloom mode.
+func (f *BfFrontier) Import(r io.Reader) (TransferStats, error) {
+	var stats TransferStats
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return stats, err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(zr)
+
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return stats, err
+	}
+	if header.Format != exportFormat {
+		return stats, errors.New("Not a frontier export")
+	}
+	if header.Version > exportVersion {
+		return stats, fmt.Errorf("Unsupported export version: %d", header.Version)
+	}
+
+	for {
+		var rec exportRecord
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return stats, nil
+			}
+			return stats, err
+		}
+
+		switch {
+		case rec.Host != nil:
+			kept, err := f.importHost(header.SeenMode, rec.Host)
+			if err != nil {
+				return stats, fmt.Errorf("Failed to import %s: %w", rec.Host.Id, err)
+			}
+			stats.Hosts++
+			if !kept {
+				stats.SkippedSeen++
+			}
+		case rec.Url != nil:
+			queued, err := f.importUrl(rec.Url)
+			if err != nil {
+				return stats, err
+			}
+			if queued {
+				stats.Urls++
+			}
+		case rec.Domain != nil:
+			if err := f.quotas.merge(domainQuotaKey(rec.Domain.Domain), rec.Domain.Quota); err != nil {
+				return stats, err
+			}
+		case rec.Recrawl != nil:
+			taken, err := f.importRecrawl(rec.Recrawl)
+			if err != nil {
+				return stats, err
+			}
+			if taken {
+				stats.Recrawl++
+			} else {
+				stats.SkippedRecrawl++
+			}
+		}
+	}
+}
+
+// importHost merges a host and reports whether its seen-set was taken.
+func (f *BfFrontier) importHost(mode string, host *exportedHost) (bool, error) {
+	id := host.Id
+
+	if err := f.quotas.merge(hostQuotaKey(id), host.Quota); err != nil {
+		return false, err
+	}
+	if f.breaker != nil && host.Health != nil {
+		if err := f.breaker.merge(id, *host.Health); err != nil {
+			return false, err
+		}
+	}
+
+	kept, err := f.importSeen(mode, id, host.Seen)
+	if err != nil {
+		return false, err
+	}
+
+	f.qmMu.Lock()
+	_, exists := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	if exists {
+		f.rtMu.Lock()
+		if _, ok := f.responseTime[id]; !ok && host.State.ResponseTime > 0 {
+			f.responseTime[id] = host.State.ResponseTime
+		}
+		if _, ok := f.crawlDelay[id]; !ok && host.State.CrawlDelay > 0 {
+			f.crawlDelay[id] = host.State.CrawlDelay
+		}
+		f.rtMu.Unlock()
+		return kept, nil
+	}
+
+	q, err := f.queueProvider.Get(id)
+	if err != nil {
+		return false, err
+	}
+	f.restoreQueue(id, q, host.State)
+	return kept, nil
+}
+
+func (f *BfFrontier) importSeen(mode string, id string, data []byte) (bool, error) {
+	if len(data) == 0 {
+		return true, nil
+	}
+
+	switch seen := f.seen.(type) {
+	case *exactSeen:
+		if mode == seenExact {
+			return true, seen.restore(id, data)
+		}
+		return seen.restoreLegacy(id, data)
+	case *bloom:
+		if mode != seenBloom {
+			return false, nil
+		}
+		if current, err := seen.stats(id); err != nil || current.FillRatio > 0 {
+			return false, err
+		}
+		return true, seen.restore(id, data)
+	}
+	return false, nil
+}
+
+func (f *BfFrontier) importUrl(u *exportedUrl) (bool, error) {
+	// revisits are of seen urls by definition
+	if !u.Revisit {
+		seen, err := f.seen.contains(u.Host, []byte(u.Url))
+		if err != nil || seen {
+			return false, err
+		}
+	}
+
+	meta := UrlMeta{
+		Depth:           u.Depth,
+		MaxDepth:        u.MaxDepth,
+		Priority:        u.Priority,
+		Inlinks:         u.Inlinks,
+		Revisit:         u.Revisit,
+		Sitemap:         u.Sitemap,
+		SitemapPriority: u.SitemapPriority,
+	}
+	if u.LastMod != nil {
+		meta.LastMod = *u.LastMod
+	}
+	return true, f.requeue(u.Host, u.Url, meta)
+}
+
+// importRecrawl takes the recrawl record of a url not known here and reports
+// whether it did.
+func (f *BfFrontier) importRecrawl(rec *exportedRecrawl) (bool, error) {
+	if f.recrawl == nil {
+		return false, nil
+	}
+
+	f.recrawl.mu.Lock()
+	defer f.recrawl.mu.Unlock()
+
+	if _, err := f.recrawl.states.Get(rec.Url); err != storage.NoSuchKeyError {
+		return false, err
+	}
+
+	if rec.State != nil {
+		if err := f.recrawl.states.Put(rec.Url, *rec.State); err != nil {
+			return false, err
+		}
+	}
+	if rec.Due != nil {
+		err := f.recrawl.schedule.Push(ScheduledUrl{
+			Url: rec.Url,
+			At:  *rec.Due,
+			UrlMeta: UrlMeta{
+				Depth:           rec.Depth,
+				MaxDepth:        rec.MaxDepth,
+				Priority:        rec.Priority,
+				Revisit:         true,
+				Sitemap:         rec.Sitemap,
+				SitemapPriority: rec.SitemapPriority,
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package frontier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func TestExportImport(t *testing.T) {
+	quota := WithHostQuota(Quota{MaxPages: 1000})
+	src := newTestFrontier(WithMaxActiveQueues(1), quota)
+	lastMod := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2", "http://b.com/1"} {
+		if err := src.Put(mustParse(t, raw), UrlMeta{Depth: 2, Priority: 7, SitemapPriority: 8, Sitemap: true, LastMod: lastMod}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	u, _, _, err := src.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.MarkSuccessful(u, UrlMeta{}, FetchInfo{Size: 100}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buf bytes.Buffer
+	stats, err := src.Export(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if stats.Hosts != 2 || stats.Urls != 2 {
+		t.Fatalf("Unexpected export stats: %+v", stats)
+	}
+	export := buf.Bytes()
+
+	dst := newTestFrontier(quota)
+	if _, err := dst.Import(bytes.NewReader(export)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for id, want := range map[string]int{"a.com": 1, "b.com": 1} {
+		if have := dst.queueMap[id].Len(); have != want {
+			t.Fatalf("Unexpected number of urls of %s. Have: %d, want: %d", id, have, want)
+		}
+	}
+	head, _ := dst.queueMap["b.com"].Head(1)
+	if head[0].Depth != 2 || head[0].Priority != 7 || head[0].SitemapPriority != 8 || !head[0].Sitemap || !head[0].LastMod.Equal(lastMod) {
+		t.Fatalf("Url metadata was lost: %+v", head[0])
+	}
+	if seen, _ := dst.seen.contains("a.com", []byte(u.String())); !seen {
+		t.Fatalf("Seen url was lost")
+	}
+
+	// merging it again adds up the stats
+	if _, err := dst.Import(bytes.NewReader(export)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if quota, _ := dst.quotas.get(hostQuotaKey("a.com")); quota.Pages != 2 || quota.Bytes != 200 {
+		t.Fatalf("Unexpected quota stats after a merge: %+v", quota)
+	}
+
+	// bloom filters become legacy filters of an exact seen-set
+	exact := newTestFrontier(WithExactSeen(inmem.NewSet()))
+	if _, err := exact.Import(bytes.NewReader(export)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if seen, _ := exact.seen.contains("a.com", []byte(u.String())); !seen {
+		t.Fatalf("Seen url was lost in exact mode")
+	}
+}
+
+func TestExportRecrawl(t *testing.T) {
+	src := newTestFrontier(WithRecrawl(DefaultRecrawlPolicy()))
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2"} {
+		if err := src.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	fetched, meta, _, err := src.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.MarkSuccessful(fetched, meta, FetchInfo{Digest: "abc"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	// a revisit of a seen url, and a url in flight
+	if err := src.Put(fetched, UrlMeta{Revisit: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	inflight, _, _, err := src.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buf bytes.Buffer
+	stats, err := src.Export(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if stats.InFlight != 1 || stats.Recrawl != 1 {
+		t.Fatalf("Unexpected export stats: %+v", stats)
+	}
+	export := buf.Bytes()
+
+	dst := newTestFrontier(WithRecrawl(DefaultRecrawlPolicy()))
+	if _, err := dst.Import(bytes.NewReader(export)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if have := dst.queueMap["a.com"].Len(); have != 2 {
+		t.Fatalf("Revisit or url in flight was lost. Have %d urls, want 2", have)
+	}
+	head, _ := dst.queueMap["a.com"].Head(2)
+	if head[0].Url != fetched.String() && head[1].Url != fetched.String() {
+		t.Fatalf("Revisit was dropped as seen: %+v", head)
+	}
+	if head[0].Url != inflight.String() && head[1].Url != inflight.String() {
+		t.Fatalf("Url in flight was lost: %+v", head)
+	}
+	if state, err := dst.recrawl.states.Get(fetched.String()); err != nil || state.Digest != "abc" {
+		t.Fatalf("Recrawl state was lost: %+v, %v", state, err)
+	}
+	if dst.recrawl.schedule.Len() != 1 {
+		t.Fatalf("Scheduled revisit was lost")
+	}
+
+	// known urls keep their recrawl state, and without recrawl there is none
+	stats, err = dst.Import(bytes.NewReader(export))
+	if err != nil || stats.SkippedRecrawl != 1 || dst.recrawl.schedule.Len() != 1 {
+		t.Fatalf("Recrawl state of a known url was imported: %+v, %v", stats, err)
+	}
+	if stats, _ := newTestFrontier().Import(bytes.NewReader(export)); stats.SkippedRecrawl != 1 {
+		t.Fatalf("Recrawl records were not skipped: %+v", stats)
+	}
+}
+
+func TestImportVersion(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"format":"aracno-frontier","version":99}` + "\n"))
+	zw.Close()
+
+	_, err := newTestFrontier().Import(&buf)
+	if err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("Unsupported version was not rejected: %v", err)
+	}
+}
@@ -1,25 +1,44 @@
 package frontier
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/xunterr/aracno/internal/hostname"
 	"github.com/xunterr/aracno/internal/storage"
 	"github.com/xunterr/aracno/internal/storage/inmem"
 )
 
 type Frontier interface {
-	Get() (*url.URL, time.Time, error)
+	Get(ctx context.Context) (*url.URL, UrlMeta, time.Time, error)
 	MarkProcessed(*url.URL) error
-	MarkSuccessful(*url.URL, time.Duration) error
-	MarkFailed(*url.URL) error
-	Put(*url.URL) error
+	MarkSuccessful(*url.URL, UrlMeta, FetchInfo) error
+	MarkFailed(*url.URL, error) error
+	MarkRetry(*url.URL, UrlMeta, time.Duration) error
+	Put(*url.URL, UrlMeta) error
 }
 
+// FetchInfo describes a successful fetch.
+type FetchInfo struct {
+	TTR  time.Duration
+	Size int64
+
+	// Digest identifies the content, to tell whether it changed.
+	Digest string
+	// MaxAge is the freshness lifetime from Cache-Control or Expires.
+	MaxAge time.Duration
+	// LastModified comes from the Last-Modified header.
+	LastModified time.Time
+}
+
+var ErrClosed = errors.New("Frontier is closed")
+
 type QueueProvider interface {
 	Get(string) (storage.Queue[Url], error)
 }
@@ -39,8 +58,52 @@ type bfFrontierOpts struct {
 	maxActiveQueues      int
 	politenessMultiplier int
 	defaultSessionBudget int
+
+	hostQuota    Quota
+	domainQuota  Quota
+	quotaAction  QuotaAction
+	quotaStorage QuotaStorage
+
+	maxDepth      uint32
+	enqueueFilter EnqueueFilter
+	onEnqueued    UrlDiscoveredCallback
+
+	inlinkCacheSize uint
+
+	politenessGroup PolitenessGroup
+	resolver        HostResolver
+
+	leaseTTL     time.Duration
+	leaseStorage LeaseStorage
+
+	breaker       bool
+	breakerPolicy BreakerPolicy
+	healthStorage HealthStorage
+
+	exactSeen         storage.Set
+	seenCacheSize     uint
+	seenFlushInterval time.Duration
+
+	stateStorage       StateStorage
+	checkpointInterval time.Duration
+
+	scheduler Scheduler
+	clock     func() time.Time
+
+	maxInflight  map[string]int
+	crawlWindows map[string][]CrawlWindow
+
+	recrawl         bool
+	recrawlPolicy   RecrawlPolicy
+	recrawlStorage  RecrawlStorage
+	recrawlSchedule RecrawlSchedule
 }
 
+// EnqueueFilter decides whether a url is enqueued. It is called before the url
+// is deduplicated, stored or sent to another node, so it has to be cheap. A
+// rejected url is counted under the returned reason.
+type EnqueueFilter func(url *url.URL) (ok bool, reason string)
+
 type BfFrontierOption func(*bfFrontierOpts)
 
 func defaultOpts() bfFrontierOpts {
@@ -48,6 +111,17 @@ func defaultOpts() bfFrontierOpts {
 		maxActiveQueues:      256,
 		politenessMultiplier: 10,
 		defaultSessionBudget: 20,
+		quotaAction:          QuotaPark,
+		quotaStorage:         inmem.NewInMemoryStorage[QuotaStats](),
+		resolver:             defaultResolver,
+		leaseTTL:             10 * time.Minute,
+		seenCacheSize:        4096,
+		seenFlushInterval:    5 * time.Second,
+		checkpointInterval:   30 * time.Second,
+		breakerPolicy:        DefaultBreakerPolicy(),
+		healthStorage:        inmem.NewInMemoryStorage[HostHealth](),
+		scheduler:            NewTimeScheduler(),
+		clock:                time.Now,
 	}
 }
 
@@ -69,6 +143,212 @@ func WithSessionBudget(sb int) BfFrontierOption {
 	}
 }
 
+// WithHostQuota limits the pages and bytes fetched from a single host.
+func WithHostQuota(quota Quota) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.hostQuota = quota
+	}
+}
+
+// WithDomainQuota limits the pages and bytes fetched from all hosts of a
+// registered domain together.
+func WithDomainQuota(quota Quota) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.domainQuota = quota
+	}
+}
+
+// WithQuotaAction sets what happens to the urls of a host that exceeds its quota.
+func WithQuotaAction(action QuotaAction) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.quotaAction = action
+	}
+}
+
+// WithQuotaStorage sets where per host and per domain fetch stats are kept.
+func WithQuotaStorage(storage QuotaStorage) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.quotaStorage = storage
+	}
+}
+
+// WithMaxDepth sets the maximum number of hops from a seed for urls without
+// their own depth limit. Zero means unlimited.
+func WithMaxDepth(maxDepth uint32) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.maxDepth = maxDepth
+	}
+}
+
+// WithInlinkTracking counts how often each url is discovered, remembering
+// up to size urls. A url that is rediscovered while still queued is queued
+// again each time its inlink count doubles, so that a priority ordered queue
+// can move it forward; the copy fetched last is skipped as already seen.
+func WithInlinkTracking(size uint) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.inlinkCacheSize = size
+	}
+}
+
+// WithRecrawl enables continuous crawling: every fetched url is scheduled
+// for a revisit at an interval estimated from how often it changes.
+func WithRecrawl(policy RecrawlPolicy) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.recrawl = true
+		fo.recrawlPolicy = policy
+	}
+}
+
+// WithRecrawlStorage sets where the change history and the schedule of
+// revisits are kept.
+func WithRecrawlStorage(states RecrawlStorage, schedule RecrawlSchedule) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.recrawlStorage = states
+		fo.recrawlSchedule = schedule
+	}
+}
+
+// WithPolitenessGroup makes the hosts of a group share their politeness:
+// only one of their queues is crawled at a time, and the delay after a
+// request to one of them applies to all of them.
+func WithPolitenessGroup(group PolitenessGroup) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.politenessGroup = group
+	}
+}
+
+// WithHostResolver sets how hosts are resolved for the ip politeness groups.
+func WithHostResolver(resolver HostResolver) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.resolver = resolver
+	}
+}
+
+// WithLeaseTTL sets how long a url handed out by Get may stay
+// unacknowledged before it is queued again.
+func WithLeaseTTL(ttl time.Duration) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.leaseTTL = ttl
+	}
+}
+
+// WithLeaseStorage sets where the urls in flight are kept. With a persistent
+// storage, urls in flight during a crash are queued again by RecoverLeases.
+func WithLeaseStorage(storage LeaseStorage) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.leaseStorage = storage
+	}
+}
+
+// WithCircuitBreaker tracks the consecutive DNS errors, refused connections
+// and timeouts of each host. A failing host is put on cooldown, and retired
+// with its urls parked once it keeps failing.
+func WithCircuitBreaker(policy BreakerPolicy) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.breaker = true
+		fo.breakerPolicy = policy
+	}
+}
+
+// WithHealthStorage sets where the failure state of hosts is kept.
+func WithHealthStorage(storage HealthStorage) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.healthStorage = storage
+	}
+}
+
+// WithExactSeen keeps the seen urls in set, exactly, instead of in the
+// bloom filters. The bloom storage given to NewBfFrontier is still read, so
+// that urls seen before the switch are not crawled again.
+func WithExactSeen(set storage.Set) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.exactSeen = set
+	}
+}
+
+// WithSeenCacheSize sets how many bloom filters of hosts, or lookup filters
+// of hosts with an exact seen-set, are kept in memory.
+func WithSeenCacheSize(size uint) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.seenCacheSize = size
+	}
+}
+
+// WithSeenFlushInterval sets how often changed bloom filters are written back
+// to their storage. Urls seen since the last write are crawled again after a
+// crash.
+func WithSeenFlushInterval(interval time.Duration) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.seenFlushInterval = interval
+	}
+}
+
+// WithStateStorage keeps the scheduling state of the queues in storage. It is
+// saved periodically and on Close, and restored by LoadQueues.
+func WithStateStorage(storage StateStorage) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.stateStorage = storage
+	}
+}
+
+// WithCheckpointInterval sets how often the scheduling state is saved.
+func WithCheckpointInterval(interval time.Duration) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.checkpointInterval = interval
+	}
+}
+
+// WithScheduler sets how the next host to crawl is picked among the active
+// ones.
+func WithScheduler(scheduler Scheduler) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.scheduler = scheduler
+	}
+}
+
+// WithClock sets the clock queues are scheduled by, for simulations. Leases
+// and background tasks still use the system clock.
+func WithClock(clock func() time.Time) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.clock = clock
+	}
+}
+
+// WithMaxInflight lets the given hosts, or all hosts of the given registered
+// domains, have more than one request in flight. The requests of such a host
+// are spread over its politeness delay. Other hosts have one request in
+// flight at a time.
+func WithMaxInflight(limits map[string]int) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.maxInflight = limits
+	}
+}
+
+// WithCrawlWindows restricts the crawl of the given hosts, or of all hosts of
+// the given registered domains, to their windows. Outside of its windows the
+// queue of a host stays inactive and doesn't take one of the active slots.
+func WithCrawlWindows(windows map[string][]CrawlWindow) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.crawlWindows = windows
+	}
+}
+
+// WithOnEnqueued sets a callback for every url that is newly enqueued: not
+// seen before and not a revisit. Without inlink tracking, a url put twice
+// before it is fetched is reported twice.
+func WithOnEnqueued(callback UrlDiscoveredCallback) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.onEnqueued = callback
+	}
+}
+
+// WithEnqueueFilter sets the filter urls have to pass to be enqueued.
+func WithEnqueueFilter(filter EnqueueFilter) BfFrontierOption {
+	return func(fo *bfFrontierOpts) {
+		fo.enqueueFilter = filter
+	}
+}
+
 type BfFrontier struct {
 	opts bfFrontierOpts
 
@@ -80,12 +360,27 @@ type BfFrontier struct {
 	queueMap map[string]*FrontierQueue
 	qmMu     sync.Mutex
 
-	bloom *bloom
+	seen   seenSet
+	quotas *quotas
+
+	inlinks  *inmem.LruCache[uint32]
+	inlinkMu sync.Mutex
+
+	recrawl *recrawl
+
+	groups *groups
 
-	nextQueue *inmem.PriorityQueue[string]
+	leases *leases
+
+	breaker *breaker
+
+	scheduler Scheduler
+	scheduled map[string]time.Time
+	paused    bool
 	block     *sync.Cond
 
 	responseTime map[string]time.Duration
+	crawlDelay   map[string]time.Duration
 	rtMu         sync.Mutex
 
 	inactiveQueues storage.Queue[string]
@@ -93,11 +388,12 @@ type BfFrontier struct {
 
 	qeMu       sync.Mutex
 	onQueueEnd map[string][]chan struct{}
+
+	closed atomic.Bool
+	done   chan struct{}
 }
 
 func NewBfFrontier(qp QueueProvider, bloomStorage BloomStorage, opts ...BfFrontierOption) *BfFrontier {
-	pq := inmem.NewPriorityQueue[string]()
-
 	defaultOpts := defaultOpts()
 
 	for _, fn := range opts {
@@ -114,31 +410,73 @@ func NewBfFrontier(qp QueueProvider, bloomStorage BloomStorage, opts ...BfFronti
 		queueMap: make(map[string]*FrontierQueue),
 		block:    sync.NewCond(new(sync.Mutex)),
 
-		bloom: newBloom(bloomStorage),
+		quotas: newQuotas(defaultOpts.quotaStorage, defaultOpts.hostQuota, defaultOpts.domainQuota),
 
 		responseTime:   make(map[string]time.Duration),
-		nextQueue:      pq,
+		crawlDelay:     make(map[string]time.Duration),
+		scheduler:      defaultOpts.scheduler,
+		scheduled:      make(map[string]time.Time),
 		inactiveQueues: inmem.NewQueue[string](),
 		onQueueEnd:     make(map[string][]chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	if defaultOpts.inlinkCacheSize > 0 {
+		f.inlinks = inmem.NewLruCache[uint32](defaultOpts.inlinkCacheSize)
+	}
+
+	leaseStorage := defaultOpts.leaseStorage
+	if leaseStorage == nil {
+		leaseStorage = inmem.NewInMemoryStorage[Lease]()
+	}
+	f.leases = newLeases(defaultOpts.leaseTTL, leaseStorage)
+	go f.expireLeases()
+
+	if defaultOpts.exactSeen != nil {
+		f.seen = newExactSeen(defaultOpts.exactSeen, bloomStorage, defaultOpts.seenCacheSize)
+	} else {
+		f.seen = newBloom(bloomStorage, defaultOpts.seenCacheSize)
+	}
+	go f.flushSeen()
+
+	if defaultOpts.stateStorage != nil {
+		go f.checkpoint()
+	}
+
+	if len(defaultOpts.crawlWindows) > 0 {
+		go f.openWindows()
+	}
+
+	if defaultOpts.breaker {
+		f.breaker = newBreaker(defaultOpts.breakerPolicy, defaultOpts.healthStorage)
+	}
+
+	if defaultOpts.politenessGroup != GroupHost {
+		f.groups = newGroups(defaultOpts.politenessGroup, defaultOpts.resolver, func(w waitingQueue) {
+			f.setNextQueue(w.id, w.at)
+		})
 	}
 
-	go func() {
-		t := time.Tick(time.Duration(time.Second * 2))
-		for range t {
-			f.displayDebug()
+	if defaultOpts.recrawl {
+		f.recrawl = &recrawl{
+			policy:   defaultOpts.recrawlPolicy,
+			states:   defaultOpts.recrawlStorage,
+			schedule: defaultOpts.recrawlSchedule,
 		}
-	}()
+		if f.recrawl.states == nil {
+			f.recrawl.states = inmem.NewInMemoryStorage[RecrawlState]()
+		}
+		if f.recrawl.schedule == nil {
+			f.recrawl.schedule = NewMemorySchedule()
+		}
+		go f.revisitDue()
+	}
 
 	return f
 }
 
-func (f *BfFrontier) displayDebug() {
-	f.aqMu.Lock()
-	defer f.aqMu.Unlock()
-	fmt.Printf("Active queues: %d\n", f.activeQueues)
-	fmt.Printf("Actual active queues: %d\n", f.calculateActiveQueues())
-	fmt.Printf("Ready queues: %d\n", f.nextQueue.Length())
-	fmt.Printf("Inactive queues: %d\n", f.inactiveQueues.Len())
+func (f *BfFrontier) now() time.Time {
+	return f.opts.clock().UTC()
 }
 
 func (f *BfFrontier) calculateActiveQueues() int {
@@ -154,26 +492,74 @@ func (f *BfFrontier) calculateActiveQueues() int {
 	return counter
 }
 
-func (f *BfFrontier) Get() (*url.URL, time.Time, error) {
+// Get returns the next url to crawl and when to crawl it. It blocks until a
+// queue is ready or the context is done.
+func (f *BfFrontier) Get(ctx context.Context) (*url.URL, UrlMeta, time.Time, error) {
 	for {
-		url, accessAt, err := f.getNextUrl()
+		url, meta, accessAt, err := f.getNextUrl(ctx)
 		if err != nil {
-			return nil, time.Time{}, err
+			return nil, UrlMeta{}, time.Time{}, err
 		}
 
 		id := toId(url)
 
-		hit, err := f.bloom.checkBloom(id, []byte(url.String()))
-		if err != nil {
-			return nil, time.Time{}, err
+		// revisits were fetched before, so they are always seen
+		if !meta.Revisit {
+			hit, err := f.seen.contains(id, []byte(url.String()))
+			if err != nil {
+				return nil, UrlMeta{}, time.Time{}, err
+			}
+
+			if hit {
+				f.reschedule(id, f.getNextRequestTime(id))
+				continue
+			}
 		}
 
-		if hit {
-			f.setNextQueue(id, f.getNextRequestTime(id))
-		} else {
-			return url, accessAt, nil
+		limit := f.inflightLimit(id)
+		free, err := f.leases.acquire(id, url.String(), meta, limit)
+		if err != nil {
+			f.requeue(id, url.String(), meta)
+			f.reschedule(id, f.now())
+			return nil, UrlMeta{}, time.Time{}, err
 		}
+		if free {
+			// the next request may go out before this one is done
+			from := accessAt
+			if now := f.now(); now.After(from) {
+				from = now
+			}
+			f.setNextQueue(id, from.Add(f.politenessDelay(id)/time.Duration(limit)))
+		}
+		return url, meta, accessAt, nil
+	}
+}
+
+// inflightLimit returns how many requests to the host may be in flight.
+func (f *BfFrontier) inflightLimit(id string) int {
+	if limit, ok := lookupDomain(f.opts.maxInflight, id); ok && limit > 1 {
+		return limit
 	}
+	return 1
+}
+
+// finish releases the lease of a url whose fetch ended and schedules its
+// queue at next, unless the queue is still scheduled for another request.
+// A url whose lease expired was queued again, and its queue scheduled, then.
+func (f *BfFrontier) finish(id string, u string, next time.Time) error {
+	leased, unparked, err := f.leases.release(u)
+	if leased && unparked {
+		f.reschedule(id, next)
+		return err
+	}
+	f.releaseGroup(id, next)
+	return err
+}
+
+// abandon releases the lease of a url whose queue is gone.
+func (f *BfFrontier) abandon(id string, u string) {
+	f.leases.release(u)
+	f.releaseGroup(id, f.now())
 }
 
 func toId(url *url.URL) string {
@@ -184,23 +570,85 @@ func toId(url *url.URL) string {
 	return url.Hostname()
 }
 
-func (f *BfFrontier) getNextUrl() (*url.URL, time.Time, error) {
-	queueIndex, accessAt, ok := f.getNextQueue()
-	if !ok {
-		return nil, time.Time{}, errors.New("Failed to get new queue index")
+func (f *BfFrontier) getNextUrl(ctx context.Context) (*url.URL, UrlMeta, time.Time, error) {
+	queueIndex, accessAt, err := f.acquireNextQueue(ctx)
+	if err != nil {
+		return nil, UrlMeta{}, time.Time{}, err
 	}
 
-	u, ok := f.dequeueFrom(queueIndex)
-	if !ok {
-		return nil, time.Time{}, errors.New(fmt.Sprintf("Failed to dequeue from queue: %s", queueIndex))
+	var u Url
+	for {
+		var ok bool
+		u, ok = f.dequeueFrom(queueIndex)
+		if !ok {
+			f.releaseGroup(queueIndex, f.now())
+			return nil, UrlMeta{}, time.Time{}, errors.New(fmt.Sprintf("Failed to dequeue from queue: %s", queueIndex))
+		}
+
+		if f.inlinks == nil || u.Revisit {
+			break
+		}
+
+		// skip copies of rediscovered urls that were already fetched
+		seen, err := f.seen.contains(queueIndex, []byte(u.Url))
+		if err != nil || !seen {
+			break
+		}
+	}
+
+	if u.Revisit {
+		f.revisitServed(queueIndex)
 	}
 
 	url, err := url.Parse(u.Url)
 	if err != nil {
-		return url, time.Time{}, err
+		f.releaseGroup(queueIndex, f.now())
+		return url, UrlMeta{}, time.Time{}, err
 	}
 
-	return url, accessAt, nil
+	return url, u.UrlMeta, accessAt, nil
+}
+
+// acquireNextQueue returns the next queue to crawl whose politeness group
+// is free. Queues of a busy group wait for it to be released.
+func (f *BfFrontier) acquireNextQueue(ctx context.Context) (string, time.Time, error) {
+	for {
+		queueIndex, accessAt, err := f.getNextQueue(ctx)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if f.groups == nil {
+			return queueIndex, accessAt, nil
+		}
+
+		next, ok := f.groups.acquire(queueIndex, accessAt)
+		if ok {
+			return queueIndex, accessAt, nil
+		}
+		if !next.IsZero() {
+			f.setNextQueue(queueIndex, next)
+		}
+	}
+}
+
+// releaseGroup frees the politeness group of the queue until next and
+// schedules the queues that waited for it. The group stays with the queue
+// while requests of the queue are in flight.
+func (f *BfFrontier) releaseGroup(queueId string, next time.Time) {
+	if f.groups == nil || f.leases.count(queueId) > 0 {
+		return
+	}
+
+	for _, w := range f.groups.release(queueId, next) {
+		f.setNextQueue(w.id, w.at)
+	}
+}
+
+// reschedule schedules a queue that was crawled at the given time.
+func (f *BfFrontier) reschedule(queueId string, at time.Time) {
+	f.releaseGroup(queueId, at)
+	f.setNextQueue(queueId, at)
 }
 
 func (f *BfFrontier) dequeueFrom(queueId string) (Url, bool) {
@@ -212,6 +660,13 @@ func (f *BfFrontier) dequeueFrom(queueId string) (Url, bool) {
 		return Url{}, false
 	}
 
+	if queue.IsActive() && !f.inWindow(queueId) {
+		queue.Deactivate()
+		windowParkedQueues.Inc()
+		f.swapQueue(queueId)
+		return Url{}, false
+	}
+
 	u, ok := queue.Dequeue()
 	if !ok {
 		f.swapQueue(queueId)
@@ -227,17 +682,141 @@ func (f *BfFrontier) swapQueue(queueId string) {
 	f.wakeInactiveQueue()
 }
 
-func (f *BfFrontier) Put(url *url.URL) error {
+// revisitDue queues the urls whose revisit is due, every few seconds.
+func (f *BfFrontier) revisitDue() {
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-f.done:
+			return
+		}
+
+		f.queueDue(f.now())
+	}
+}
+
+// queueDue queues the urls whose revisit is due at now. A url stays
+// scheduled until it was queued, so that a failed enqueue is retried on the
+// next tick.
+func (f *BfFrontier) queueDue(now time.Time) {
+	f.recrawl.visitDue(now, 10_000, func(s ScheduledUrl) error {
+		u, err := url.Parse(s.Url)
+		if err != nil {
+			// it would never parse, drop it
+			return nil
+		}
+		if err := f.enqueue(u, s.UrlMeta); err != nil {
+			return err
+		}
+		revisitedUrls.Inc()
+		f.revisitQueued(toId(u), s.At)
+		return nil
+	})
+}
+
+// revisitQueued tells a deadline scheduler that a revisit of the queue is
+// due at the given time.
+func (f *BfFrontier) revisitQueued(id string, at time.Time) {
+	scheduler, ok := f.scheduler.(DeadlineScheduler)
+	if !ok {
+		return
+	}
+	f.block.L.Lock()
+	scheduler.Due(id, at)
+	f.block.L.Unlock()
+}
+
+// revisitServed tells a deadline scheduler that a revisit of the queue was
+// handed out.
+func (f *BfFrontier) revisitServed(id string) {
+	scheduler, ok := f.scheduler.(DeadlineScheduler)
+	if !ok {
+		return
+	}
+	f.block.L.Lock()
+	scheduler.Served(id)
+	f.block.L.Unlock()
+}
+
+// countInlink increments and returns the number of times the url was discovered.
+func (f *BfFrontier) countInlink(url *url.URL) uint32 {
+	f.inlinkMu.Lock()
+	defer f.inlinkMu.Unlock()
+
+	key := url.String()
+	count, _ := f.inlinks.Get(key)
+	count++
+	f.inlinks.Put(key, count)
+	return count
+}
+
+// accepts runs the enqueue stage: the depth limit and the enqueue filter.
+func (f *BfFrontier) accepts(url *url.URL, meta UrlMeta) bool {
+	if meta.ExceedsDepth(f.opts.maxDepth) {
+		rejectedUrls.WithLabelValues("depth").Inc()
+		return false
+	}
+
+	if f.opts.enqueueFilter == nil {
+		return true
+	}
+
+	ok, reason := f.opts.enqueueFilter(url)
+	if !ok {
+		rejectedUrls.WithLabelValues(reason).Inc()
+	}
+	return ok
+}
+
+func (f *BfFrontier) Put(url *url.URL, meta UrlMeta) error {
+	if !f.accepts(url, meta) {
+		return nil
+	}
+	return f.enqueue(url, meta)
+}
+
+// enqueue adds a url that already passed the enqueue stage.
+func (f *BfFrontier) enqueue(url *url.URL, meta UrlMeta) error {
+	if f.closed.Load() {
+		return ErrClosed
+	}
+
 	id := toId(url)
-	ok, err := f.bloom.checkBloom(id, []byte(url.String()))
+	ok, err := f.seen.contains(id, []byte(url.String()))
 	if err != nil {
 		return err
 	}
 
-	if ok {
+	if ok && !meta.Revisit {
+		if f.recrawl != nil && !meta.LastMod.IsZero() {
+			_, err := f.recrawl.modified(url.String(), meta, f.now())
+			return err
+		}
 		return nil
 	}
 
+	if f.inlinks != nil && !meta.Revisit {
+		inlinks := f.countInlink(url)
+		if inlinks > 1 && inlinks&(inlinks-1) != 0 {
+			return nil
+		}
+		meta.Inlinks = inlinks
+	}
+
+	overQuota, err := f.quotas.exceeded(id)
+	if err != nil {
+		return err
+	}
+
+	if overQuota {
+		quotaUrls.WithLabelValues(f.opts.quotaAction.String()).Inc()
+		if f.opts.quotaAction == QuotaDrop {
+			return nil
+		}
+	}
+
 	f.qmMu.Lock()
 	queue, ok := f.queueMap[id]
 	f.qmMu.Unlock()
@@ -251,22 +830,277 @@ func (f *BfFrontier) Put(url *url.URL) error {
 	}
 
 	queue.Enqueue(Url{
-		Url:    url.String(),
-		Weight: uint32(f.calculateUrlWeight(id)),
+		Url:     url.String(),
+		Weight:  uint32(f.calculateUrlWeight(id)),
+		UrlMeta: meta,
 	})
+
+	if f.opts.onEnqueued != nil && !meta.Revisit && meta.Inlinks <= 1 {
+		f.opts.onEnqueued(url)
+	}
 	return nil
 }
 
-func (f *BfFrontier) MarkSuccessful(url *url.URL, ttr time.Duration) error {
+func (f *BfFrontier) MarkSuccessful(url *url.URL, meta UrlMeta, info FetchInfo) error {
+	id := toId(url)
+	if f.recrawl != nil {
+		state, err := f.recrawl.update(url.String(), meta, info, f.now())
+		if err != nil {
+			return err
+		}
+		recrawlInterval.Observe(state.Interval.Seconds())
+	}
+
 	f.rtMu.Lock()
-	f.responseTime[toId(url)] = ttr
+	f.responseTime[id] = info.TTR
 	f.rtMu.Unlock()
 
+	if f.breaker != nil {
+		if err := f.breaker.success(id); err != nil {
+			return err
+		}
+	}
+
+	hostOver, domainOver, err := f.quotas.add(id, info.Size)
+	if err != nil {
+		return err
+	}
+
+	if hostOver && f.retireQueue(id) {
+		quotaExceeded.WithLabelValues("host").Inc()
+	}
+	if domainOver {
+		f.retireDomain(hostname.RegisteredDomain(id))
+	}
+
 	return f.MarkProcessed(url)
 }
 
-func (f *BfFrontier) MarkFailed(url *url.URL) error {
-	return f.MarkProcessed(url)
+// retireQueue stops scheduling the queue and drops or parks its urls
+// depending on the quota action. It returns false if the queue was already retired.
+func (f *BfFrontier) retireQueue(id string) bool {
+	queue, ok := f.retire(id)
+	if !ok {
+		return false
+	}
+
+	if f.opts.quotaAction == QuotaDrop {
+		quotaUrls.WithLabelValues(QuotaDrop.String()).Add(float64(queue.Drain()))
+	} else {
+		quotaUrls.WithLabelValues(QuotaPark.String()).Add(float64(queue.Len()))
+	}
+	return true
+}
+
+// retire stops scheduling the queue, keeping its urls. It returns false if
+// the queue was already retired.
+func (f *BfFrontier) retire(id string) (*FrontierQueue, bool) {
+	f.qmMu.Lock()
+	queue, ok := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	if !ok || queue.IsRetired() {
+		return nil, false
+	}
+
+	queue.Retire()
+	retiredQueues.Inc()
+
+	go f.notifyAllOnEnd(id)
+	return queue, true
+}
+
+func (f *BfFrontier) retireDomain(domain string) {
+	f.qmMu.Lock()
+	var ids []string
+	for id := range f.queueMap {
+		if hostname.RegisteredDomain(id) == domain {
+			ids = append(ids, id)
+		}
+	}
+	f.qmMu.Unlock()
+
+	quotaExceeded.WithLabelValues("domain").Inc()
+	for _, id := range ids {
+		f.retireQueue(id)
+	}
+}
+
+// SetCrawlDelay sets the minimum delay between two requests to the host of
+// the given url, as requested by its robots.txt.
+func (f *BfFrontier) SetCrawlDelay(url *url.URL, delay time.Duration) {
+	f.rtMu.Lock()
+	f.crawlDelay[toId(url)] = delay
+	f.rtMu.Unlock()
+}
+
+// MarkFailed marks the url as seen after a failed fetch. With a circuit
+// breaker, errors that mean the host is unreachable count against the host.
+func (f *BfFrontier) MarkFailed(url *url.URL, fetchErr error) error {
+	if f.recrawl != nil {
+		// the lease is the only place that still knows the metadata
+		lease, _ := f.leases.get(url.String())
+		if _, err := f.recrawl.failed(url.String(), lease.UrlMeta, f.now()); err != nil {
+			return err
+		}
+	}
+
+	var breakerErr error
+	if f.breaker != nil {
+		breakerErr = f.hostFailed(toId(url), fetchErr)
+	}
+
+	if err := f.MarkProcessed(url); err != nil {
+		return err
+	}
+	return breakerErr
+}
+
+func (f *BfFrontier) hostFailed(id string, fetchErr error) error {
+	kind, ok := classifyFailure(fetchErr)
+	if !ok {
+		return nil
+	}
+	hostFailures.WithLabelValues(string(kind)).Inc()
+
+	health, err := f.breaker.failure(id, kind, f.now())
+	if err != nil {
+		return err
+	}
+
+	if health.Dead {
+		if _, ok := f.retire(id); ok {
+			deadHosts.Inc()
+		}
+	} else if !health.CooldownUntil.IsZero() {
+		hostCooldowns.Inc()
+	}
+	return nil
+}
+
+// Purge drops the urls of all queues whose id (host) matches and returns the
+// number of dropped urls. The queues stay scheduled and are refilled if new
+// urls of the host are put.
+func (f *BfFrontier) Purge(match func(id string) bool) int {
+	return f.PurgeUrls(match, nil)
+}
+
+// PurgeUrls drops the urls for which drop returns true from all queues whose
+// id (host) matches, and returns the number of dropped urls. A nil drop drops
+// all urls of the matching queues.
+func (f *BfFrontier) PurgeUrls(match func(id string) bool, drop func(*url.URL) bool) int {
+	f.qmMu.Lock()
+	var queues []*FrontierQueue
+	for id, q := range f.queueMap {
+		if match(id) {
+			queues = append(queues, q)
+		}
+	}
+	f.qmMu.Unlock()
+
+	var n int
+	for _, q := range queues {
+		if drop == nil {
+			n += q.Drain()
+			continue
+		}
+		n += q.DrainMatching(func(u Url) bool {
+			parsed, err := url.Parse(u.Url)
+			return err == nil && drop(parsed)
+		})
+	}
+	purgedUrls.Add(float64(n))
+	return n
+}
+
+// Close stops the frontier: urls are no longer accepted, the scheduling state
+// is saved and the seen urls still cached in memory are written to their
+// storage. The queue and seen
+// storages themselves are not closed.
+func (f *BfFrontier) Close() error {
+	if f.closed.Swap(true) {
+		return nil
+	}
+	close(f.done)
+	if err := f.Checkpoint(); err != nil {
+		return err
+	}
+	return f.seen.flush()
+}
+
+// PauseHost stops handing out urls of the host until it is resumed. Urls of
+// the host are still queued meanwhile.
+func (f *BfFrontier) PauseHost(host string) error {
+	if !f.setQueuePaused(host, true) {
+		return errors.New("Failed to pause host")
+	}
+	return nil
+}
+
+func (f *BfFrontier) ResumeHost(host string) error {
+	if !f.setQueuePaused(host, false) {
+		return errors.New("Failed to resume host")
+	}
+	f.wakeInactiveQueue()
+	return nil
+}
+
+// PurgeHost drops the queued urls of the host and forgets which of its urls
+// were seen, so that they can be crawled again. It returns the number of
+// dropped urls.
+func (f *BfFrontier) PurgeHost(host string) (int, error) {
+	n := f.Purge(func(id string) bool {
+		return id == host
+	})
+	return n, f.seen.forget(host)
+}
+
+// Pause stops handing out urls until Resume is called. Get blocks meanwhile.
+func (f *BfFrontier) Pause() error {
+	f.block.L.Lock()
+	f.paused = true
+	f.block.L.Unlock()
+	return nil
+}
+
+func (f *BfFrontier) Resume() error {
+	f.block.L.Lock()
+	f.paused = false
+	f.block.Broadcast()
+	f.block.L.Unlock()
+	return nil
+}
+
+func (f *BfFrontier) Paused() bool {
+	f.block.L.Lock()
+	defer f.block.L.Unlock()
+	return f.paused
+}
+
+// MarkRetry puts the url back at the end of its queue without marking it as
+// seen. The queue is not scheduled again before after has passed.
+func (f *BfFrontier) MarkRetry(url *url.URL, meta UrlMeta, after time.Duration) error {
+	id := toId(url)
+	f.qmMu.Lock()
+	queue, ok := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	if !ok {
+		f.abandon(id, url.String())
+		return errors.New("No such queue")
+	}
+
+	queue.Enqueue(Url{
+		Url:     url.String(),
+		Weight:  uint32(f.calculateUrlWeight(id)),
+		UrlMeta: meta,
+	})
+
+	next := f.getNextRequestTime(id)
+	if retryAt := f.now().Add(after); retryAt.After(next) {
+		next = retryAt
+	}
+	return f.finish(id, url.String(), next)
 }
 
 func (f *BfFrontier) MarkProcessed(url *url.URL) error {
@@ -276,6 +1110,7 @@ func (f *BfFrontier) MarkProcessed(url *url.URL) error {
 	f.qmMu.Unlock()
 
 	if !ok {
+		f.abandon(id, url.String())
 		return errors.New("No such queue")
 	}
 
@@ -283,10 +1118,95 @@ func (f *BfFrontier) MarkProcessed(url *url.URL) error {
 		go f.notifyAllOnEnd(id)
 	}
 
-	after := f.getNextRequestTime(id)
-	f.setNextQueue(id, after)
+	seenErr := f.seen.add(id, []byte(url.String()))
+	if err := f.finish(id, url.String(), f.getNextRequestTime(id)); err != nil {
+		return err
+	}
+	return seenErr
+}
+
+// requeue puts a url that was handed out back into its queue, bypassing
+// the enqueue stage and the seen check.
+func (f *BfFrontier) requeue(id string, u string, meta UrlMeta) error {
+	f.qmMu.Lock()
+	queue, ok := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	if !ok {
+		var err error
+		queue, err = f.addNewDefaultQueue(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	queue.Enqueue(Url{
+		Url:     u,
+		Weight:  uint32(f.calculateUrlWeight(id)),
+		UrlMeta: meta,
+	})
+	return nil
+}
+
+// RecoverLeases queues again the urls that were in flight when a previous
+// run stopped without acknowledging them. It has to be called after the
+// queues were loaded.
+func (f *BfFrontier) RecoverLeases() (int, error) {
+	recovered, err := f.leases.recover()
+	var n int
+	for _, lease := range recovered {
+		if err := f.requeue(lease.Queue, lease.Url, lease.UrlMeta); err != nil {
+			return n, err
+		}
+		n++
+	}
+	leaseRequeues.WithLabelValues("recovered").Add(float64(n))
+	return n, err
+}
+
+// flushSeen periodically writes the seen urls cached in memory to their
+// storage.
+func (f *BfFrontier) flushSeen() {
+	t := time.NewTicker(f.opts.seenFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			f.seen.flush()
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// expireLeases queues again the urls whose lease expired.
+func (f *BfFrontier) expireLeases() {
+	period := max(f.leases.ttl/10, time.Second)
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-f.done:
+			return
+		}
+
+		f.expire(time.Now())
+	}
+}
 
-	return f.bloom.addBloom(id, []byte(url.String()))
+// expire queues again the urls whose lease expired before now.
+func (f *BfFrontier) expire(now time.Time) {
+	// leases removed before an error are still queued, the rest expires on the next tick
+	expired, _ := f.leases.expired(now)
+	for _, lease := range expired {
+		if err := f.requeue(lease.Queue, lease.Url, lease.UrlMeta); err == nil {
+			leaseRequeues.WithLabelValues("expired").Inc()
+		}
+		if f.leases.unpark(lease.Queue) {
+			f.reschedule(lease.Queue, f.getNextRequestTime(lease.Queue))
+		}
+	}
 }
 
 func (f *BfFrontier) notifyAllOnEnd(queueID string) {
@@ -330,16 +1250,9 @@ func (f *BfFrontier) NotifyOnEnd(queueID string) chan struct{} {
 }
 
 func (f *BfFrontier) setQueueLock(queueID string, locked bool) bool {
-	f.qmMu.Lock()
-	queue, ok := f.queueMap[queueID]
-	f.qmMu.Unlock()
-
+	queue, ok := f.getOrAddQueue(queueID)
 	if !ok {
-		var err error
-		queue, err = f.addNewDefaultQueue(queueID)
-		if err != nil {
-			return false
-		}
+		return false
 	}
 
 	if locked {
@@ -351,15 +1264,47 @@ func (f *BfFrontier) setQueueLock(queueID string, locked bool) bool {
 	return true
 }
 
-func (f *BfFrontier) wakeInactiveQueue() {
+func (f *BfFrontier) setQueuePaused(queueID string, paused bool) bool {
+	queue, ok := f.getOrAddQueue(queueID)
+	if !ok {
+		return false
+	}
+
+	if paused {
+		queue.Pause()
+	} else {
+		queue.Resume()
+	}
+
+	return true
+}
+
+func (f *BfFrontier) getOrAddQueue(queueID string) (*FrontierQueue, bool) {
+	f.qmMu.Lock()
+	queue, ok := f.queueMap[queueID]
+	f.qmMu.Unlock()
+
+	if !ok {
+		var err error
+		queue, err = f.addNewDefaultQueue(queueID)
+		if err != nil {
+			return nil, false
+		}
+	}
+	return queue, true
+}
+
+// wakeInactiveQueue activates an inactive queue if there is a free slot and
+// reports whether it did.
+func (f *BfFrontier) wakeInactiveQueue() bool {
 	if !f.incActiveCountIfCan() {
-		return
+		return false
 	}
 
 	id, queue, ok := f.findAvailableInactiveQueue(f.inactiveQueues.Len())
 	if !ok {
 		f.decreaseActiveCount()
-		return
+		return false
 	}
 
 	queue.Reset(f.calculateSessionBudget(id))
@@ -368,7 +1313,8 @@ func (f *BfFrontier) wakeInactiveQueue() {
 	f.queueMap[id] = queue
 	f.qmMu.Unlock()
 
-	f.setNextQueue(id, time.Now().UTC())
+	f.setNextQueue(id, f.now())
+	return true
 }
 
 func (f *BfFrontier) calculateSessionBudget(queueId string) uint64 {
@@ -434,11 +1380,11 @@ func (f *BfFrontier) findAvailableInactiveQueue(depth int) (string, *FrontierQue
 		f.qmMu.Lock()
 		inactiveQueue, ok := f.queueMap[inactiveQueueID]
 		f.qmMu.Unlock()
-		if !ok {
+		if !ok || inactiveQueue.IsRetired() {
 			continue
 		}
 
-		if !inactiveQueue.IsLocked() && !inactiveQueue.IsEmpty() {
+		if !inactiveQueue.IsLocked() && !inactiveQueue.IsPaused() && !inactiveQueue.IsEmpty() && f.inWindow(inactiveQueueID) {
 			return inactiveQueueID, inactiveQueue, true
 		} else {
 			f.enqueueInactiveId(inactiveQueueID)
@@ -456,11 +1402,11 @@ func (f *BfFrontier) enqueueInactiveId(queueId string) {
 
 func (f *BfFrontier) dequeueInactiveId() (id string, ok bool) {
 	f.iqMu.Lock()
+	defer f.iqMu.Unlock()
 	id, err := f.inactiveQueues.Pop()
 	if err != nil {
 		return "", false
 	}
-	f.iqMu.Unlock()
 	return id, true
 }
 
@@ -482,35 +1428,70 @@ func (f *BfFrontier) incActiveCountIfCan() bool {
 	}
 }
 
-func (f *BfFrontier) getNextRequestTime(id string) time.Time {
+// politenessDelay returns how long to wait between two requests to the host.
+func (f *BfFrontier) politenessDelay(id string) time.Duration {
 	after := time.Duration(1 * time.Second)
 
 	f.rtMu.Lock()
 	if responseTime, ok := f.responseTime[id]; ok {
 		after = responseTime * time.Duration(f.opts.politenessMultiplier)
 	}
+	if delay, ok := f.crawlDelay[id]; ok && delay > after {
+		after = delay
+	}
 	f.rtMu.Unlock()
+	return after
+}
 
-	return time.Now().UTC().Add(after)
-
+func (f *BfFrontier) getNextRequestTime(id string) time.Time {
+	next := f.now().Add(f.politenessDelay(id))
+	if f.breaker != nil {
+		if until := f.breaker.cooldownUntil(id); until.After(next) {
+			next = until
+		}
+	}
+	return next
 }
 
-func (f *BfFrontier) getNextQueue() (string, time.Time, bool) {
+func (f *BfFrontier) getNextQueue(ctx context.Context) (string, time.Time, error) {
+	// wake up the wait below when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		f.block.L.Lock()
+		f.block.Broadcast()
+		f.block.L.Unlock()
+	})
+	defer stop()
+
 	f.block.L.Lock()
-	for f.nextQueue.Length() == 0 {
+	for f.paused || f.scheduler.Len() == 0 {
+		if err := ctx.Err(); err != nil {
+			f.block.L.Unlock()
+			return "", time.Time{}, err
+		}
 		f.block.Wait()
 	}
-	index, priority, ok := f.nextQueue.Pop()
-	f.block.L.Unlock()
+	if err := ctx.Err(); err != nil {
+		f.block.L.Unlock()
+		return "", time.Time{}, err
+	}
 
-	accessAt := time.Unix(0, int64(priority)*int64(time.Millisecond))
+	index, accessAt, ok := f.scheduler.Pop(f.now())
+	if at, scheduled := f.scheduled[index]; scheduled && at.Equal(accessAt) {
+		delete(f.scheduled, index)
+	}
+	f.block.L.Unlock()
 
-	return index, accessAt, ok
+	if !ok {
+		return "", time.Time{}, errors.New("Failed to get new queue index")
+	}
+	return index, accessAt, nil
 }
 
 func (f *BfFrontier) setNextQueue(queueIndex string, at time.Time) {
 	f.block.L.Lock()
-	f.nextQueue.Push(queueIndex, int(at.UnixMilli()))
+	at = time.UnixMilli(at.UnixMilli())
+	f.scheduler.Push(queueIndex, at)
+	f.scheduled[queueIndex] = at
 	f.block.Signal()
 	f.block.L.Unlock()
 }
@@ -524,8 +1505,31 @@ func (f *BfFrontier) addNewDefaultQueue(queueID string) (*FrontierQueue, error)
 	return f.addNewQueue(queueID, q), nil
 }
 
+// isRetired reports whether the queue of the host has to stay retired because
+// it is over quota or dead.
+func (f *BfFrontier) isRetired(id string) bool {
+	if overQuota, err := f.quotas.exceeded(id); err == nil && overQuota {
+		return true
+	}
+	return f.breaker != nil && f.breaker.dead(id)
+}
+
 func (f *BfFrontier) addNewQueue(id string, q storage.Queue[Url]) *FrontierQueue {
-	active := f.incActiveCountIfCan()
+	if f.isRetired(id) {
+		queue := NewFrontierQueue(q, false, 0)
+		queue.Retire()
+
+		f.qmMu.Lock()
+		f.queueMap[id] = queue
+		f.qmMu.Unlock()
+		return queue
+	}
+
+	if f.groups != nil {
+		f.groups.prefetch(id)
+	}
+
+	active := f.inWindow(id) && f.incActiveCountIfCan()
 	queue := NewFrontierQueue(q, active, uint64(f.opts.defaultSessionBudget))
 
 	f.qmMu.Lock()
@@ -533,15 +1537,10 @@ func (f *BfFrontier) addNewQueue(id string, q storage.Queue[Url]) *FrontierQueue
 	f.qmMu.Unlock()
 
 	if active {
-		f.setNextQueue(id, time.Now().UTC())
+		f.setNextQueue(id, f.now())
 	} else {
 		f.enqueueInactiveId(id)
 	}
 
 	return queue
 }
-func (f *BfFrontier) LoadQueues(queues map[string]storage.Queue[Url]) {
-	for k, queue := range queues {
-		f.addNewQueue(k, queue)
-	}
-}
@@ -0,0 +1,550 @@
+package frontier
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func TestEnqueueStage(t *testing.T) {
+	f := newTestFrontier(
+		WithMaxDepth(2),
+		WithEnqueueFilter(func(u *url.URL) (bool, string) {
+			return !strings.HasPrefix(u.Path, "/private"), "scope"
+		}),
+	)
+
+	puts := map[string]UrlMeta{
+		"http://a.com/1":         {Depth: 2},
+		"http://a.com/2":         {Depth: 3},
+		"http://a.com/3":         {Depth: 3, MaxDepth: 5},
+		"http://a.com/private/1": {},
+	}
+
+	for raw, meta := range puts {
+		if err := f.Put(mustParse(t, raw), meta); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if have := f.queueMap["a.com"].Len(); have != 2 {
+		t.Fatalf("Unexpected number of enqueued urls. Have: %d, want: 2", have)
+	}
+}
+
+func TestMarkRetry(t *testing.T) {
+	f := newTestFrontier()
+	u := mustParse(t, "http://a.com/1")
+
+	if _, err := f.leases.acquire("a.com", u.String(), UrlMeta{}, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.MarkRetry(u, UrlMeta{}, time.Minute); err == nil {
+		t.Fatalf("Expected an error for a url without a queue")
+	}
+	if _, leased := f.leases.get(u.String()); leased {
+		t.Fatalf("Lease of a url without a queue was kept")
+	}
+
+	if err := f.Put(u, UrlMeta{Depth: 1}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.MarkRetry(u, UrlMeta{Depth: 1}, time.Minute); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if have := f.queueMap["a.com"].Len(); have != 2 {
+		t.Fatalf("Retried url was not requeued. Have: %d urls, want: 2", have)
+	}
+
+	seen, err := f.seen.contains("a.com", []byte(u.String()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if seen {
+		t.Fatalf("Retried url was marked as seen")
+	}
+}
+
+func TestUrlScore(t *testing.T) {
+	ordered := []UrlMeta{
+		{Priority: 2, Depth: 5},
+		{Priority: 1, Depth: 1, Inlinks: 64},
+		{Priority: 1, Depth: 1, Inlinks: 2, SitemapPriority: 8},
+		{Priority: 1, Depth: 1, Inlinks: 2, SitemapPriority: 5},
+		{Priority: 1, Depth: 1, Inlinks: 2},
+		{Priority: 1, Depth: 3, Inlinks: 2},
+		{Depth: 0},
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		higher := Url{UrlMeta: ordered[i-1]}.Score()
+		lower := Url{UrlMeta: ordered[i]}.Score()
+		if higher <= lower {
+			t.Errorf("Unexpected order of %+v (%d) and %+v (%d)", ordered[i-1], higher, ordered[i], lower)
+		}
+	}
+}
+
+func TestInlinkTracking(t *testing.T) {
+	f := newTestFrontier(WithInlinkTracking(100))
+	u := mustParse(t, "http://a.com/popular")
+
+	for i := 0; i < 5; i++ {
+		if err := f.Put(u, UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// queued on the 1st, 2nd and 4th discovery
+	queue := f.queueMap["a.com"]
+	if have := queue.Len(); have != 3 {
+		t.Fatalf("Unexpected number of queued copies. Have: %d, want: 3", have)
+	}
+
+	var last Url
+	for !queue.IsEmpty() {
+		last, _ = queue.queue.Pop()
+	}
+	if last.Inlinks != 4 {
+		t.Fatalf("Unexpected inlink count. Have: %d, want: 4", last.Inlinks)
+	}
+}
+
+func TestRecrawlPolicy(t *testing.T) {
+	p := RecrawlPolicy{Initial: 24 * time.Hour, Min: time.Hour, Max: 10 * 24 * time.Hour}
+	now := time.Now()
+
+	first := p.next(RecrawlState{}, false, FetchInfo{Digest: "a"}, now)
+	if first.Interval != 24*time.Hour {
+		t.Fatalf("Unexpected initial interval: %s", first.Interval)
+	}
+
+	old := p.next(RecrawlState{}, false, FetchInfo{Digest: "a", LastModified: now.Add(-50 * 24 * time.Hour)}, now)
+	if old.Interval != 5*24*time.Hour {
+		t.Fatalf("Unexpected interval for an old page: %s", old.Interval)
+	}
+
+	unchanged := p.next(first, true, FetchInfo{Digest: "a"}, now)
+	if unchanged.Interval != 36*time.Hour || unchanged.Changes != 0 {
+		t.Fatalf("Unexpected state after an unchanged fetch: %+v", unchanged)
+	}
+
+	changed := p.next(unchanged, true, FetchInfo{Digest: "b"}, now)
+	if changed.Interval != 18*time.Hour || changed.Changes != 1 || changed.Checks != 3 {
+		t.Fatalf("Unexpected state after a changed fetch: %+v", changed)
+	}
+
+	cached := p.next(changed, true, FetchInfo{Digest: "c", MaxAge: 48 * time.Hour}, now)
+	if cached.Interval != 48*time.Hour {
+		t.Fatalf("Interval shorter than max age: %s", cached.Interval)
+	}
+
+	bounded := p.next(RecrawlState{Interval: 90 * time.Minute, Digest: "a"}, true, FetchInfo{Digest: "b"}, now)
+	if bounded.Interval != time.Hour {
+		t.Fatalf("Interval below the minimum: %s", bounded.Interval)
+	}
+}
+
+func TestRecrawlSchedule(t *testing.T) {
+	r := &recrawl{
+		policy:   RecrawlPolicy{Initial: time.Hour, Min: time.Minute, Max: 24 * time.Hour},
+		states:   inmem.NewInMemoryStorage[RecrawlState](),
+		schedule: NewMemorySchedule(),
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := r.update("http://a.com/1", UrlMeta{Depth: 2}, FetchInfo{Digest: "a"}, now); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var due []ScheduledUrl
+	collect := func(s ScheduledUrl) error {
+		due = append(due, s)
+		return nil
+	}
+	if _, err := r.visitDue(now, 10, collect); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(due) != 0 {
+		t.Fatalf("Url due before its interval passed")
+	}
+
+	// a url that could not be queued stays scheduled
+	later := now.Add(2 * time.Hour)
+	if _, err := r.visitDue(later, 10, func(ScheduledUrl) error { return errors.New("Queue is closed") }); err == nil {
+		t.Fatalf("Visit error was not returned")
+	}
+	if _, err := r.visitDue(later, 10, collect); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(due) != 1 || due[0].Depth != 2 || !due[0].Revisit || r.schedule.Len() != 0 {
+		t.Fatalf("Unexpected due urls: %+v", due)
+	}
+
+	// failed revisits back off, urls never fetched are not recrawled
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if ok, err := r.failed("http://a.com/1", due[0].UrlMeta, later); err != nil || !ok {
+			t.Fatalf("Failed revisit was not rescheduled: %v", err)
+		}
+		next, _ := r.schedule.Pop()
+		if have := next.At.Sub(later); have != want {
+			t.Fatalf("Unexpected backoff after %d failures. Have: %s, want: %s", i+1, have, want)
+		}
+	}
+	if ok, _ := r.failed("http://a.com/2", UrlMeta{}, later); ok {
+		t.Fatalf("Url that was never fetched was rescheduled")
+	}
+	state, _ := r.update("http://a.com/1", UrlMeta{}, FetchInfo{Digest: "a"}, later)
+	if state.Failures != 0 {
+		t.Fatalf("Failures were not reset by a successful fetch")
+	}
+}
+
+func TestRecrawlRevisit(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := newTestFrontier(
+		WithClock(clock.Now),
+		WithRecrawl(RecrawlPolicy{Initial: time.Hour, Min: time.Minute, Max: 24 * time.Hour}),
+	)
+
+	if err := f.Put(mustParse(t, "http://a.com/1"), UrlMeta{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	u, meta, _, err := f.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.MarkSuccessful(u, meta, FetchInfo{Digest: "a"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+	f.queueDue(clock.now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	u, meta, _, err = f.Get(ctx)
+	if err != nil {
+		t.Fatalf("Revisit was not handed out: %v", err)
+	}
+	if u.String() != "http://a.com/1" || !meta.Revisit {
+		t.Fatalf("Unexpected revisit: %s, %+v", u, meta)
+	}
+	if f.recrawl.schedule.Len() != 0 {
+		t.Fatalf("Revisit is still scheduled")
+	}
+
+	// the next visit is scheduled once the revisit is done
+	if err := f.MarkSuccessful(u, meta, FetchInfo{Digest: "a"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if f.recrawl.schedule.Len() != 1 {
+		t.Fatalf("Next visit was not scheduled")
+	}
+}
+
+func TestGetCanceled(t *testing.T) {
+	f := newTestFrontier()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, _, _, err := f.Get(ctx)
+		done <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Get did not return after the context was canceled")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := f.Put(mustParse(t, "http://a.com/1"), UrlMeta{}); err != ErrClosed {
+		t.Fatalf("Unexpected error after close: %v", err)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	leaseStorage := inmem.NewInMemoryStorage[Lease]()
+	f := newTestFrontier(WithLeaseStorage(leaseStorage), WithLeaseTTL(time.Minute))
+
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{Depth: 1}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	first, _, _, err := f.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if all, _ := leaseStorage.GetAll(); len(all) != 1 {
+		t.Fatalf("Unexpected number of leases: %d", len(all))
+	}
+
+	if err := f.MarkProcessed(first); err != nil {
+		t.Fatal(err.Error())
+	}
+	if all, _ := leaseStorage.GetAll(); len(all) != 0 {
+		t.Fatalf("Lease was not released: %+v", all)
+	}
+
+	second, _, _, err := f.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lease, _ := f.leases.get(second.String())
+	if lease.Url != second.String() || lease.Depth != 1 {
+		t.Fatalf("Unexpected lease: %+v", lease)
+	}
+
+	// a late ack of an expired lease doesn't schedule the queue again
+	f.expire(time.Now().Add(2 * time.Minute))
+	head, _ := f.queueMap["a.com"].Head(1)
+	if len(head) != 1 || head[0].Url != second.String() || head[0].Depth != 1 {
+		t.Fatalf("Expired url was not queued again: %+v", head)
+	}
+	if err := f.MarkProcessed(second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if have := f.scheduler.Len(); have != 1 {
+		t.Fatalf("Queue was scheduled %d times after a late ack", have)
+	}
+
+	// a new frontier over the same lease storage, as after a crash
+	if err := leaseStorage.Put(second.String(), lease); err != nil {
+		t.Fatal(err.Error())
+	}
+	restarted := newTestFrontier(WithLeaseStorage(leaseStorage))
+	n, err := restarted.RecoverLeases()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 1 || restarted.queueMap["a.com"].Len() != 1 {
+		t.Fatalf("Leased url was not recovered")
+	}
+	if all, _ := leaseStorage.GetAll(); len(all) != 0 {
+		t.Fatalf("Recovered lease was kept: %+v", all)
+	}
+}
+
+func TestMaxInflight(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := newTestFrontier(WithMaxInflight(map[string]int{"example.com": 2}), WithClock(clock.Now))
+
+	for _, raw := range []string{"http://cdn.example.com/1", "http://cdn.example.com/2", "http://cdn.example.com/3", "http://b.com/1", "http://b.com/2"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	get := func() (*url.URL, time.Time, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		u, _, at, err := f.Get(ctx)
+		return u, at, err
+	}
+
+	var first, second *url.URL
+	var firstAt, secondAt time.Time
+	for first == nil || second == nil {
+		u, at, err := get()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		switch {
+		case toId(u) == "b.com":
+		case first == nil:
+			first, firstAt = u, at
+		default:
+			second, secondAt = u, at
+		}
+	}
+	// both requests fit into the default politeness delay of a second
+	if have := secondAt.Sub(firstAt); have != 500*time.Millisecond {
+		t.Fatalf("Unexpected spacing of parallel requests: %v", have)
+	}
+	if queue, _ := f.Queue("cdn.example.com", 0); queue.Inflight != 2 {
+		t.Fatalf("Unexpected number of urls in flight: %d", queue.Inflight)
+	}
+
+	// b.com is in flight, and cdn.example.com has no free slot
+	if u, _, err := get(); err == nil {
+		t.Fatalf("Url handed out over the limit: %s", u)
+	}
+
+	if err := f.MarkProcessed(first); err != nil {
+		t.Fatal(err.Error())
+	}
+	u, _, err := get()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if toId(u) != "cdn.example.com" {
+		t.Fatalf("Freed slot was not used: %s", u)
+	}
+}
+
+func TestMaxInflightGroup(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := newTestFrontier(
+		WithMaxInflight(map[string]int{"example.com": 2}),
+		WithPolitenessGroup(GroupDomain),
+		WithClock(clock.Now),
+	)
+
+	get := func() (*url.URL, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		u, _, _, err := f.Get(ctx)
+		return u, err
+	}
+
+	for _, raw := range []string{"http://cdn.example.com/1", "http://cdn.example.com/2"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	var inflight []*url.URL
+	for len(inflight) < 2 {
+		u, err := get()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		inflight = append(inflight, u)
+	}
+	if err := f.Put(mustParse(t, "http://www.example.com/1"), UrlMeta{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the group is held while a request of the queue is in flight
+	if err := f.MarkProcessed(inflight[0]); err != nil {
+		t.Fatal(err.Error())
+	}
+	for {
+		u, err := get()
+		if err == context.DeadlineExceeded {
+			break
+		}
+		if err == nil {
+			t.Fatalf("Url of the same group handed out while a request was in flight: %s", u)
+		}
+	}
+
+	if err := f.MarkProcessed(inflight[1]); err != nil {
+		t.Fatal(err.Error())
+	}
+	for {
+		u, err := get()
+		if err == context.DeadlineExceeded {
+			t.Fatalf("Group was not released after the last request")
+		}
+		if err == nil {
+			if toId(u) != "www.example.com" {
+				t.Fatalf("Unexpected url: %s", u)
+			}
+			break
+		}
+	}
+}
+
+func TestOnEnqueued(t *testing.T) {
+	var enqueued []string
+	f := newTestFrontier(WithOnEnqueued(func(u *url.URL) {
+		enqueued = append(enqueued, u.String())
+	}))
+
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	f.MarkProcessed(mustParse(t, "http://a.com/2"))
+	f.Put(mustParse(t, "http://a.com/2"), UrlMeta{})
+	f.Put(mustParse(t, "http://a.com/3"), UrlMeta{Revisit: true})
+
+	if len(enqueued) != 2 {
+		t.Fatalf("Unexpected enqueued urls: %v", enqueued)
+	}
+}
+
+func TestPurgeUrls(t *testing.T) {
+	f := newTestFrontier()
+	for _, raw := range []string{"http://a.com/1", "http://a.com/private/2", "http://a.com/3", "http://b.com/private/1"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	n := f.PurgeUrls(func(id string) bool { return id == "a.com" }, func(u *url.URL) bool {
+		return strings.HasPrefix(u.Path, "/private/")
+	})
+	if n != 1 || f.queueMap["b.com"].Len() != 1 {
+		t.Fatalf("Unexpected purge of %d urls", n)
+	}
+	head, _ := f.queueMap["a.com"].Head(2)
+	if len(head) != 2 || head[0].Url != "http://a.com/1" || head[1].Url != "http://a.com/3" {
+		t.Fatalf("Kept urls were reordered: %+v", head)
+	}
+}
+
+func TestRecrawlLastMod(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)}
+	f := newTestFrontier(
+		WithClock(clock.Now),
+		WithRecrawl(RecrawlPolicy{Initial: 24 * time.Hour, Min: time.Hour, Max: 30 * 24 * time.Hour}),
+	)
+
+	// the lastmod of a sitemap stands in for a missing Last-Modified
+	listed := UrlMeta{LastMod: time.Date(2023, 12, 22, 0, 0, 0, 0, time.UTC)}
+	if err := f.Put(mustParse(t, "http://a.com/1"), listed); err != nil {
+		t.Fatal(err.Error())
+	}
+	u, meta, _, err := f.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	state, err := f.recrawl.update(u.String(), meta, FetchInfo{Digest: "a"}, clock.now)
+	if err != nil || state.Interval != 48*time.Hour {
+		t.Fatalf("Unexpected interval from the sitemap lastmod: %s, %v", state.Interval, err)
+	}
+	if err := f.MarkProcessed(u); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// an older lastmod doesn't move the visit
+	if err := f.Put(u, listed); err != nil {
+		t.Fatal(err.Error())
+	}
+	if f.recrawl.schedule.Len() != 1 {
+		t.Fatalf("Visit was moved by an old lastmod")
+	}
+
+	// a newer one does, and the old entry is dropped once due
+	clock.now = clock.now.Add(time.Hour)
+	if err := f.Put(u, UrlMeta{LastMod: clock.now.Add(-time.Minute)}); err != nil {
+		t.Fatal(err.Error())
+	}
+	var due []ScheduledUrl
+	f.recrawl.visitDue(clock.now.Add(48*time.Hour), 10, func(s ScheduledUrl) error {
+		due = append(due, s)
+		return nil
+	})
+	if len(due) != 1 || !due[0].At.Equal(clock.now) || f.recrawl.schedule.Len() != 0 {
+		t.Fatalf("Unexpected visits after a sitemap change: %+v", due)
+	}
+}
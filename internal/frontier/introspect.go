@@ -0,0 +1,243 @@
+package frontier
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+// FrontierStats is a summary of the queues of a frontier.
+type FrontierStats struct {
+	Queues          int  `json:"queues"`
+	ActiveQueues    int  `json:"active_queues"`
+	ActiveCount     int  `json:"active_count"`
+	MaxActiveQueues int  `json:"max_active_queues"`
+	ReadyQueues     int  `json:"ready_queues"`
+	InactiveQueues  int  `json:"inactive_queues"`
+	Paused          bool `json:"paused"`
+	Leases          int  `json:"leases"`
+}
+
+type QueueState string
+
+const (
+	QueueActive   QueueState = "active"
+	QueueInactive QueueState = "inactive"
+	QueueLocked   QueueState = "locked"
+	QueuePaused   QueueState = "paused"
+	QueueRetired  QueueState = "retired"
+	QueueDead     QueueState = "dead"
+	// QueueOutsideWindow is an inactive queue whose crawl window is closed.
+	QueueOutsideWindow QueueState = "outside_window"
+)
+
+// QueueStats describes a single host queue.
+type QueueStats struct {
+	Id            string     `json:"id"`
+	Size          int        `json:"size"`
+	State         QueueState `json:"state"`
+	SessionBudget uint64     `json:"session_budget"`
+	// NextAccess is when the queue is scheduled; it is empty for queues
+	// that are not scheduled or are being crawled.
+	NextAccess     *time.Time `json:"next_access,omitempty"`
+	ResponseTimeMs *int64     `json:"response_time_ms,omitempty"`
+	CrawlDelayMs   *int64     `json:"crawl_delay_ms,omitempty"`
+	Group          string     `json:"group,omitempty"`
+	// Failures is the number of consecutive failures of the host, with a
+	// circuit breaker.
+	Failures      uint32     `json:"failures,omitempty"`
+	LastFailure   string     `json:"last_failure,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	// Inflight is the number of urls of the host handed out and not
+	// acknowledged yet.
+	Inflight int `json:"inflight,omitempty"`
+}
+
+// BloomStats describes the bloom filter of urls seen on a host.
+type BloomStats struct {
+	Capacity  uint    `json:"capacity"`
+	Hashes    uint    `json:"hashes"`
+	FillRatio float64 `json:"fill_ratio"`
+}
+
+// QueuedUrl is a url waiting in a queue.
+type QueuedUrl struct {
+	Url             string `json:"url"`
+	Depth           uint32 `json:"depth"`
+	Priority        uint32 `json:"priority"`
+	SitemapPriority uint32 `json:"sitemap_priority"`
+	Inlinks         uint32 `json:"inlinks"`
+	Revisit         bool   `json:"revisit"`
+	Sitemap         bool   `json:"sitemap"`
+}
+
+// QueueDetails is a queue with the urls at its front and, without an exact
+// seen-set, its bloom filter.
+type QueueDetails struct {
+	QueueStats
+	Head  []QueuedUrl `json:"head"`
+	Bloom *BloomStats `json:"bloom,omitempty"`
+}
+
+var NoSuchQueueError = errors.New("No such queue")
+
+func (f *BfFrontier) Stats() FrontierStats {
+	f.aqMu.Lock()
+	activeCount := int(f.activeQueues)
+	f.aqMu.Unlock()
+
+	f.qmMu.Lock()
+	queues := len(f.queueMap)
+	f.qmMu.Unlock()
+
+	f.block.L.Lock()
+	ready := f.scheduler.Len()
+	paused := f.paused
+	f.block.L.Unlock()
+
+	f.iqMu.Lock()
+	inactive := f.inactiveQueues.Len()
+	f.iqMu.Unlock()
+
+	return FrontierStats{
+		Queues:          queues,
+		ActiveQueues:    f.calculateActiveQueues(),
+		ActiveCount:     activeCount,
+		MaxActiveQueues: f.opts.maxActiveQueues,
+		ReadyQueues:     ready,
+		InactiveQueues:  inactive,
+		Paused:          paused,
+		Leases:          f.leases.len(),
+	}
+}
+
+// Queues returns the stats of all queues, ordered by id.
+func (f *BfFrontier) Queues() []QueueStats {
+	f.qmMu.Lock()
+	queues := make(map[string]*FrontierQueue, len(f.queueMap))
+	for id, q := range f.queueMap {
+		queues[id] = q
+	}
+	f.qmMu.Unlock()
+
+	stats := make([]QueueStats, 0, len(queues))
+	for id, q := range queues {
+		stats = append(stats, f.queueStats(id, q))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Id < stats[j].Id
+	})
+	return stats
+}
+
+// Queue returns the stats of a queue with up to head urls from its front.
+func (f *BfFrontier) Queue(id string, head int) (QueueDetails, error) {
+	f.qmMu.Lock()
+	q, ok := f.queueMap[id]
+	f.qmMu.Unlock()
+
+	if !ok {
+		return QueueDetails{}, NoSuchQueueError
+	}
+
+	urls, err := q.Head(head)
+	if err != nil {
+		return QueueDetails{}, err
+	}
+
+	details := QueueDetails{
+		QueueStats: f.queueStats(id, q),
+		Head:       make([]QueuedUrl, 0, len(urls)),
+	}
+	for _, u := range urls {
+		details.Head = append(details.Head, QueuedUrl{
+			Url:             u.Url,
+			Depth:           u.Depth,
+			Priority:        u.Priority,
+			SitemapPriority: u.SitemapPriority,
+			Inlinks:         u.Inlinks,
+			Revisit:         u.Revisit,
+			Sitemap:         u.Sitemap,
+		})
+	}
+
+	if bloom, ok := f.seen.(*bloom); ok {
+		details.Bloom, err = bloom.stats(id)
+		if err != nil {
+			return QueueDetails{}, err
+		}
+	}
+	return details, nil
+}
+
+// InactiveQueues returns up to limit ids of the queues waiting to become
+// active, in the order they are woken.
+func (f *BfFrontier) InactiveQueues(limit int) ([]string, error) {
+	f.iqMu.Lock()
+	defer f.iqMu.Unlock()
+
+	if peeker, ok := f.inactiveQueues.(storage.Peeker[string]); ok {
+		return peeker.PeekN(limit)
+	}
+	return nil, nil
+}
+
+func (f *BfFrontier) queueStats(id string, q *FrontierQueue) QueueStats {
+	stats := QueueStats{
+		Id:            id,
+		Size:          q.Len(),
+		SessionBudget: q.SessionBudget(),
+	}
+
+	switch {
+	case q.IsRetired():
+		stats.State = QueueRetired
+	case q.IsPaused():
+		stats.State = QueuePaused
+	case q.IsLocked():
+		stats.State = QueueLocked
+	case q.IsActive():
+		stats.State = QueueActive
+	case !f.inWindow(id):
+		stats.State = QueueOutsideWindow
+	default:
+		stats.State = QueueInactive
+	}
+
+	f.block.L.Lock()
+	if at, ok := f.scheduled[id]; ok {
+		stats.NextAccess = &at
+	}
+	f.block.L.Unlock()
+
+	f.rtMu.Lock()
+	if rt, ok := f.responseTime[id]; ok {
+		ms := rt.Milliseconds()
+		stats.ResponseTimeMs = &ms
+	}
+	if delay, ok := f.crawlDelay[id]; ok {
+		ms := delay.Milliseconds()
+		stats.CrawlDelayMs = &ms
+	}
+	f.rtMu.Unlock()
+
+	if f.groups != nil {
+		stats.Group, _ = f.groups.cached(id)
+	}
+	stats.Inflight = f.leases.count(id)
+
+	if f.breaker != nil {
+		health, _ := f.breaker.get(id)
+		stats.Failures = health.Failures
+		stats.LastFailure = string(health.LastFailure)
+		if health.CooldownUntil.After(time.Now()) {
+			stats.CooldownUntil = &health.CooldownUntil
+		}
+		if health.Dead && stats.State == QueueRetired {
+			stats.State = QueueDead
+		}
+	}
+	return stats
+}
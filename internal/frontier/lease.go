@@ -0,0 +1,171 @@
+package frontier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+// Lease is a url handed out by Get and not acknowledged yet.
+type Lease struct {
+	Queue   string
+	Url     string
+	Expires time.Time
+	UrlMeta
+}
+
+// LeaseStorage keeps the leases, so that urls in flight survive a crash.
+type LeaseStorage interface {
+	storage.Storage[Lease]
+	GetAll() (map[string]Lease, error)
+}
+
+type leases struct {
+	ttl     time.Duration
+	storage LeaseStorage
+
+	mu     sync.Mutex
+	active map[string]Lease
+	// inflight counts the active leases of each queue.
+	inflight map[string]int
+	// parked holds the queues that are not scheduled until one of their
+	// leases ends, because they have no free slot.
+	parked map[string]bool
+}
+
+func newLeases(ttl time.Duration, storage LeaseStorage) *leases {
+	return &leases{
+		ttl:      ttl,
+		storage:  storage,
+		active:   make(map[string]Lease),
+		inflight: make(map[string]int),
+		parked:   make(map[string]bool),
+	}
+}
+
+// acquire leases the url of the queue, which allows limit leases at a time.
+// It reports whether the queue has a free slot left; if not, the queue is
+// parked.
+func (l *leases) acquire(queue string, u string, meta UrlMeta, limit int) (bool, error) {
+	lease := Lease{
+		Queue:   queue,
+		Url:     u,
+		Expires: time.Now().Add(l.ttl),
+		UrlMeta: meta,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.storage.Put(u, lease); err != nil {
+		return false, err
+	}
+	l.active[u] = lease
+	l.inflight[queue]++
+
+	if l.inflight[queue] < limit {
+		return true, nil
+	}
+	l.parked[queue] = true
+	return false, nil
+}
+
+// remove drops an active lease. It has to be called with mu held.
+func (l *leases) remove(u string, lease Lease) error {
+	if err := l.storage.Delete(u); err != nil {
+		return err
+	}
+	delete(l.active, u)
+	if l.inflight[lease.Queue]--; l.inflight[lease.Queue] <= 0 {
+		delete(l.inflight, lease.Queue)
+	}
+	return nil
+}
+
+// release acknowledges the lease of the url, if there is one. It reports
+// whether there was one and whether its queue was parked and is not anymore.
+func (l *leases) release(u string) (leased bool, unparked bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lease, ok := l.active[u]
+	if !ok {
+		return false, false, nil
+	}
+	unparked = l.parked[lease.Queue]
+	delete(l.parked, lease.Queue)
+	return true, unparked, l.remove(u, lease)
+}
+
+// unpark reports whether the queue was parked, and is not anymore.
+func (l *leases) unpark(queue string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	parked := l.parked[queue]
+	delete(l.parked, queue)
+	return parked
+}
+
+// get returns the active lease of the url, if there is one.
+func (l *leases) get(u string) (Lease, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, ok := l.active[u]
+	return lease, ok
+}
+
+// count returns the number of active leases of the queue.
+func (l *leases) count(queue string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight[queue]
+}
+
+// expired removes and returns the leases that expired before now.
+func (l *leases) expired(now time.Time) ([]Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expired []Lease
+	for u, lease := range l.active {
+		if lease.Expires.After(now) {
+			continue
+		}
+		if err := l.remove(u, lease); err != nil {
+			return expired, err
+		}
+		expired = append(expired, lease)
+	}
+	return expired, nil
+}
+
+// recover removes and returns the leases left in the storage by a previous
+// run.
+func (l *leases) recover() ([]Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := l.storage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []Lease
+	for u, lease := range all {
+		if _, ok := l.active[u]; ok {
+			continue
+		}
+		if err := l.storage.Delete(u); err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, lease)
+	}
+	return recovered, nil
+}
+
+func (l *leases) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.active)
+}
@@ -0,0 +1,89 @@
+package frontier
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	quotaExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_quota_exceeded_total",
+		Help: "The number of hosts and domains that exceeded their quota.",
+	}, []string{"scope"})
+
+	quotaUrls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_quota_urls_total",
+		Help: "The number of urls dropped or parked because their host or domain is over quota.",
+	}, []string{"action"})
+
+	retiredQueues = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_retired_queues_total",
+		Help: "The number of queues retired, because of a quota or a dead host.",
+	})
+
+	purgedUrls = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_purged_urls_total",
+		Help: "The number of enqueued urls dropped by a purge.",
+	})
+
+	revisitedUrls = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_revisited_urls_total",
+		Help: "The number of urls queued again by the recrawl schedule.",
+	})
+
+	recrawlInterval = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crawler_recrawl_interval_seconds",
+		Help:    "The estimated revisit intervals of fetched urls.",
+		Buckets: prometheus.ExponentialBuckets(3600, 2, 12),
+	})
+
+	groupDeferrals = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_politeness_group_deferrals_total",
+		Help: "The number of times a queue was held back by another queue of its politeness group.",
+	})
+
+	leaseRequeues = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_lease_requeues_total",
+		Help: "The number of urls queued again because their lease expired or was left by a previous run.",
+	}, []string{"reason"})
+
+	hostFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_host_failures_total",
+		Help: "The number of fetches that failed because the host was unreachable.",
+	}, []string{"kind"})
+
+	hostCooldowns = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_host_cooldowns_total",
+		Help: "The number of times a failing host was put on cooldown.",
+	})
+
+	deadHosts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_dead_hosts_total",
+		Help: "The number of queues retired because their host kept failing.",
+	})
+
+	flushedFilters = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_seen_flushed_filters_total",
+		Help: "The number of changed bloom filters written back to their storage.",
+	})
+
+	checkpoints = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_checkpoints_total",
+		Help: "The number of times the scheduling state of the queues was saved.",
+	})
+
+	restoredQueues = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_restored_queues_total",
+		Help: "The number of queues whose scheduling state was restored on startup.",
+	})
+
+	windowParkedQueues = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_window_parked_queues_total",
+		Help: "The number of times an active queue was parked because its crawl window closed.",
+	})
+
+	rejectedUrls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_enqueue_rejected_total",
+		Help: "The number of urls rejected before being enqueued.",
+	}, []string{"reason"})
+)
@@ -0,0 +1,260 @@
+package frontier
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xunterr/aracno/internal/hostname"
+)
+
+// PolitenessGroup decides which hosts share their politeness: the queues of
+// a group are never crawled in parallel and wait for each other's delay.
+type PolitenessGroup int
+
+const (
+	// GroupHost gives every host its own politeness.
+	GroupHost PolitenessGroup = iota
+	// GroupDomain groups the hosts of a registered domain.
+	GroupDomain
+	// GroupIP groups the hosts resolving to the same address.
+	GroupIP
+	// GroupIP24 groups the hosts resolving to the same /24 (/64 for IPv6).
+	GroupIP24
+)
+
+func ParsePolitenessGroup(s string) (PolitenessGroup, error) {
+	switch s {
+	case "", "host":
+		return GroupHost, nil
+	case "domain":
+		return GroupDomain, nil
+	case "ip":
+		return GroupIP, nil
+	case "ip24":
+		return GroupIP24, nil
+	default:
+		return GroupHost, fmt.Errorf("Unknown politeness group: %q", s)
+	}
+}
+
+func (g PolitenessGroup) String() string {
+	switch g {
+	case GroupDomain:
+		return "domain"
+	case GroupIP:
+		return "ip"
+	case GroupIP24:
+		return "ip24"
+	default:
+		return "host"
+	}
+}
+
+// HostResolver returns the addresses of a host.
+type HostResolver func(ctx context.Context, host string) ([]net.IP, error)
+
+func defaultResolver(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+const resolveTimeout = 5 * time.Second
+
+type waitingQueue struct {
+	id string
+	at time.Time
+}
+
+type group struct {
+	// holder is the queue being crawled, if any.
+	holder  string
+	next    time.Time
+	waiting []waitingQueue
+}
+
+// groups shares the next access time of queues in the same politeness group
+// and lets only one of them be crawled at a time.
+type groups struct {
+	mode    PolitenessGroup
+	resolve HostResolver
+	// ready schedules a queue that waited for its host to be resolved.
+	ready func(waitingQueue)
+
+	mu     sync.Mutex
+	ofHost map[string]string
+	// resolving holds the hosts being resolved in the background, with the
+	// queues that wait for it.
+	resolving map[string][]waitingQueue
+	groups    map[string]*group
+}
+
+func newGroups(mode PolitenessGroup, resolve HostResolver, ready func(waitingQueue)) *groups {
+	return &groups{
+		mode:      mode,
+		resolve:   resolve,
+		ready:     ready,
+		ofHost:    make(map[string]string),
+		resolving: make(map[string][]waitingQueue),
+		groups:    make(map[string]*group),
+	}
+}
+
+// prefetch starts resolving the group of the host, so that it is known by
+// the time the queue of the host is crawled.
+func (g *groups) prefetch(host string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.key(host)
+}
+
+// key returns the group of the host if it is known. Hosts that need a DNS
+// lookup are resolved once, in the background, so key never blocks; a host
+// that can't be resolved is a group of its own. It has to be called with mu
+// held.
+func (g *groups) key(host string) (string, bool) {
+	if key, ok := g.ofHost[host]; ok {
+		return key, true
+	}
+
+	if g.mode == GroupDomain || net.ParseIP(host) != nil {
+		key := g.lookup(host)
+		g.ofHost[host] = key
+		return key, true
+	}
+
+	if _, ok := g.resolving[host]; !ok {
+		g.resolving[host] = nil
+		go g.resolveInBackground(host)
+	}
+	return "", false
+}
+
+func (g *groups) resolveInBackground(host string) {
+	key := g.lookup(host)
+
+	g.mu.Lock()
+	g.ofHost[host] = key
+	waiting := g.resolving[host]
+	delete(g.resolving, host)
+	g.mu.Unlock()
+
+	for _, w := range waiting {
+		g.ready(w)
+	}
+}
+
+// cached returns the group of the host if it was looked up already.
+func (g *groups) cached(host string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key, ok := g.ofHost[host]
+	return key, ok
+}
+
+func (g *groups) lookup(host string) string {
+	if g.mode == GroupDomain {
+		return hostname.RegisteredDomain(host)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		ips, err := g.resolve(ctx, host)
+		cancel()
+		if err != nil || len(ips) == 0 {
+			return host
+		}
+
+		ip = ips[0]
+		for _, candidate := range ips {
+			if candidate.To4() != nil {
+				ip = candidate
+				break
+			}
+		}
+	}
+
+	if g.mode == GroupIP24 {
+		if v4 := ip.To4(); v4 != nil {
+			return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+		}
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
+
+func (g *groups) get(key string) *group {
+	gr, ok := g.groups[key]
+	if !ok {
+		gr = &group{}
+		g.groups[key] = gr
+	}
+	return gr
+}
+
+// acquire takes the group of the queue for a crawl planned at the given time.
+// If another queue of the group is being crawled, or the host of the queue is
+// still being resolved, the queue waits to be released with it or passed to
+// ready and the returned time is zero. If the group is not ready yet, the
+// returned time is when it will be.
+func (g *groups) acquire(id string, at time.Time) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key, ok := g.key(id)
+	if !ok {
+		g.resolving[id] = append(g.resolving[id], waitingQueue{id: id, at: at})
+		return time.Time{}, false
+	}
+
+	gr := g.get(key)
+	if gr.holder != "" && gr.holder != id {
+		gr.waiting = append(gr.waiting, waitingQueue{id: id, at: at})
+		groupDeferrals.Inc()
+		return time.Time{}, false
+	}
+
+	if gr.next.After(at) {
+		groupDeferrals.Inc()
+		return gr.next, false
+	}
+
+	gr.holder = id
+	return at, true
+}
+
+// release frees the group held by the queue until next and returns the
+// queues that waited for it, with the times they can be crawled at.
+func (g *groups) release(id string, next time.Time) []waitingQueue {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key, ok := g.key(id)
+	if !ok {
+		// the group was never acquired
+		return nil
+	}
+
+	gr := g.get(key)
+	if gr.holder != id {
+		return nil
+	}
+
+	gr.holder = ""
+	// queues are scheduled with millisecond precision
+	next = next.Truncate(time.Millisecond)
+	if next.After(gr.next) {
+		gr.next = next
+	}
+
+	waiting := gr.waiting
+	gr.waiting = nil
+	for i := range waiting {
+		if gr.next.After(waiting[i].at) {
+			waiting[i].at = gr.next
+		}
+	}
+	return waiting
+}
@@ -0,0 +1,137 @@
+package frontier
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func fakeResolver(addrs map[string]string) HostResolver {
+	return func(ctx context.Context, host string) ([]net.IP, error) {
+		addr, ok := addrs[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []net.IP{net.ParseIP(addr)}, nil
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	resolver := fakeResolver(map[string]string{
+		"a.com": "10.0.0.1",
+		"b.com": "10.0.0.2",
+		"c.com": "2001:db8::1",
+	})
+
+	tests := []struct {
+		mode PolitenessGroup
+		host string
+		want string
+	}{
+		{GroupDomain, "www.a.co.uk", "a.co.uk"},
+		{GroupIP, "a.com", "10.0.0.1"},
+		{GroupIP, "10.0.0.7", "10.0.0.7"},
+		{GroupIP, "unknown.com", "unknown.com"},
+		{GroupIP24, "b.com", "10.0.0.0/24"},
+		{GroupIP24, "c.com", "2001:db8::/64"},
+	}
+
+	for _, test := range tests {
+		if have := newGroups(test.mode, resolver, nil).lookup(test.host); have != test.want {
+			t.Fatalf("Unexpected %s group of %s. Have: %s, want: %s", test.mode, test.host, have, test.want)
+		}
+	}
+}
+
+func TestGroupResolvesInBackground(t *testing.T) {
+	unblock := make(chan struct{})
+	resolver := func(ctx context.Context, host string) ([]net.IP, error) {
+		<-unblock
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+	ready := make(chan waitingQueue, 1)
+	g := newGroups(GroupIP, resolver, func(w waitingQueue) { ready <- w })
+
+	at := time.Now()
+	if next, ok := g.acquire("a.com", at); ok || !next.IsZero() {
+		t.Fatalf("Group of a host being resolved was acquired")
+	}
+
+	close(unblock)
+	select {
+	case w := <-ready:
+		if w.id != "a.com" || !w.at.Equal(at) {
+			t.Fatalf("Unexpected queue after the host was resolved: %+v", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Waiting queue was not scheduled after the host was resolved")
+	}
+
+	if _, ok := g.acquire("a.com", at); !ok {
+		t.Fatalf("Group of a resolved host was not acquired")
+	}
+	if key, _ := g.cached("a.com"); key != "10.0.0.1" {
+		t.Fatalf("Unexpected group: %s", key)
+	}
+}
+
+func TestPolitenessGroup(t *testing.T) {
+	f := newTestFrontier(
+		WithPolitenessGroup(GroupIP),
+		WithHostResolver(fakeResolver(map[string]string{
+			"a.com": "10.0.0.1",
+			"b.com": "10.0.0.1",
+		})),
+	)
+
+	for _, raw := range []string{"http://a.com/1", "http://b.com/1"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	first, _, _, err := f.Get(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	type got struct {
+		host string
+		at   time.Time
+	}
+	second := make(chan got)
+	go func() {
+		for {
+			// the emptied queue of the first host can come first
+			u, _, at, err := f.Get(context.Background())
+			if err == nil {
+				second <- got{u.Hostname(), at}
+				return
+			}
+		}
+	}()
+
+	select {
+	case g := <-second:
+		t.Fatalf("Got %s while %s of the same group is being crawled", g.host, first.Hostname())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	processedAt := time.Now()
+	if err := f.MarkProcessed(first); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	select {
+	case g := <-second:
+		if g.host == first.Hostname() {
+			t.Fatalf("Unexpected host: %s", g.host)
+		}
+		if g.at.Before(processedAt.Add(time.Second - 10*time.Millisecond)) {
+			t.Fatalf("The group delay was not applied. Access at: %s, processed at: %s", g.at, processedAt)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("The waiting queue was not scheduled after the group was released")
+	}
+}
@@ -1,18 +1,102 @@
 package frontier
 
 import (
+	"math"
+	"math/bits"
+	"time"
+
 	"github.com/xunterr/aracno/internal/storage"
 )
 
 type Url struct {
 	Url    string
 	Weight uint32
+	UrlMeta
+}
+
+// UrlMeta is the crawl metadata that travels with a url through the frontier.
+type UrlMeta struct {
+	// Depth is the number of hops from the seed.
+	Depth uint32
+	// MaxDepth overrides the global depth limit for everything discovered
+	// from this url. Zero means there is no override.
+	MaxDepth uint32
+	// Priority is the priority of the seed the url comes from. Higher is
+	// more important.
+	Priority uint32
+	// SitemapPriority is the priority a sitemap gave the url, in tenths:
+	// 1.0 is 10. It is zero for urls not listed by a sitemap.
+	SitemapPriority uint32
+	// Inlinks is the number of times the url was discovered before it was
+	// enqueued. It is only counted when inlink tracking is enabled.
+	Inlinks uint32
+	// Revisit is set on urls queued again by the recrawl schedule.
+	Revisit bool
+	// LastMod is when the page last changed according to the sitemap that
+	// listed it, zero if unknown.
+	LastMod time.Time
+	// Sitemap is set on sitemaps, whose urls are queued instead of links.
+	Sitemap bool
+}
+
+// Child returns the metadata of a url discovered on a page with this metadata.
+func (m UrlMeta) Child() UrlMeta {
+	return UrlMeta{
+		Depth:    m.Depth + 1,
+		MaxDepth: m.MaxDepth,
+		Priority: m.Priority,
+	}
+}
+
+// Listed returns the metadata of a url listed by a sitemap with this
+// metadata, with the lastmod and the priority (from 0 to 1) the sitemap gave
+// it. Sitemaps don't count as a hop from the seed.
+func (m UrlMeta) Listed(lastMod time.Time, priority float64, isSitemap bool) UrlMeta {
+	return UrlMeta{
+		Depth:           m.Depth,
+		MaxDepth:        m.MaxDepth,
+		Priority:        m.Priority,
+		SitemapPriority: uint32(math.Round(min(max(priority, 0), 1) * 10)),
+		LastMod:         lastMod,
+		Sitemap:         isSitemap,
+	}
+}
+
+// Score orders the urls of a priority ordered host queue; higher scores are
+// crawled first. Priority weighs the most, then the inlink count on a log
+// scale and the sitemap priority, and every hop from the seed costs a little.
+func (u Url) Score() uint32 {
+	score := int64(math.MaxUint32/2) +
+		int64(u.Priority)*1000 +
+		int64(bits.Len32(u.Inlinks))*100 +
+		int64(u.SitemapPriority)*50 -
+		int64(u.Depth)*10
+
+	if score < 0 {
+		return 0
+	}
+	if score > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(score)
+}
+
+// ExceedsDepth reports whether the url is deeper than its depth limit, which
+// is MaxDepth if set and the given global limit otherwise. Zero means unlimited.
+func (m UrlMeta) ExceedsDepth(globalMax uint32) bool {
+	limit := globalMax
+	if m.MaxDepth > 0 {
+		limit = m.MaxDepth
+	}
+	return limit > 0 && m.Depth > limit
 }
 
 type FrontierQueue struct {
 	queue         storage.Queue[Url]
 	isActive      bool
 	isLocked      bool
+	isPaused      bool
+	isRetired     bool
 	sessionBudget uint64
 }
 
@@ -30,7 +114,7 @@ func (q *FrontierQueue) Enqueue(value Url) {
 
 func (q *FrontierQueue) Dequeue() (Url, bool) {
 
-	if !q.isActive || q.IsLocked() {
+	if !q.isActive || q.IsLocked() || q.IsPaused() || q.IsRetired() {
 		return Url{}, false
 	}
 
@@ -54,14 +138,40 @@ func (q *FrontierQueue) Dequeue() (Url, bool) {
 	return url, true
 }
 
+// Head returns up to n urls from the front of the queue without removing them.
+func (q *FrontierQueue) Head(n int) ([]Url, error) {
+	if peeker, ok := q.queue.(storage.Peeker[Url]); ok {
+		return peeker.PeekN(n)
+	}
+
+	u, err := q.queue.Peek()
+	if err != nil {
+		if err == storage.NoNextItem {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []Url{u}, nil
+}
+
+func (q *FrontierQueue) SessionBudget() uint64 {
+	return q.sessionBudget
+}
+
 func (q *FrontierQueue) IsEmpty() bool {
 	return q.queue.Len() == 0
 }
 
+func (q *FrontierQueue) Len() int {
+	return q.queue.Len()
+}
+
 func (q *FrontierQueue) IsActive() bool {
 	return q.isActive
 }
 
+// IsLocked reports whether the queue is held while its host moves to another
+// node.
 func (q *FrontierQueue) IsLocked() bool {
 	return q.isLocked
 }
@@ -74,6 +184,64 @@ func (q *FrontierQueue) Unlock() {
 	q.isLocked = false
 }
 
+// IsPaused reports whether the queue was paused by an operator. Pauses and
+// locks don't undo each other.
+func (q *FrontierQueue) IsPaused() bool {
+	return q.isPaused
+}
+
+func (q *FrontierQueue) Pause() {
+	q.isPaused = true
+}
+
+func (q *FrontierQueue) Resume() {
+	q.isPaused = false
+}
+
+// Deactivate takes the queue out of the crawl until it is reset.
+func (q *FrontierQueue) Deactivate() {
+	q.isActive = false
+}
+
+func (q *FrontierQueue) IsRetired() bool {
+	return q.isRetired
+}
+
+// Retire stops the queue from being scheduled. Retired queues keep their urls.
+func (q *FrontierQueue) Retire() {
+	q.isRetired = true
+	q.isActive = false
+}
+
+// Drain removes all urls from the queue and returns how many were removed.
+func (q *FrontierQueue) Drain() int {
+	var n int
+	for {
+		if _, err := q.queue.Pop(); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+// DrainMatching removes the urls for which drop returns true and returns how
+// many were removed. The other urls are put back in their order.
+func (q *FrontierQueue) DrainMatching(drop func(Url) bool) int {
+	var n int
+	for i := q.queue.Len(); i > 0; i-- {
+		u, err := q.queue.Pop()
+		if err != nil {
+			break
+		}
+		if drop(u) {
+			n++
+			continue
+		}
+		q.queue.Push(u)
+	}
+	return n
+}
+
 func (q *FrontierQueue) Reset(sessionBudget uint64) {
 	q.isActive = true
 	q.sessionBudget = sessionBudget
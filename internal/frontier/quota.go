@@ -0,0 +1,209 @@
+package frontier
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/xunterr/aracno/internal/hostname"
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+type QuotaAction int
+
+const (
+	// QuotaPark keeps the urls of a host over quota on disk without scheduling them.
+	QuotaPark QuotaAction = iota
+	// QuotaDrop discards the urls of a host over quota.
+	QuotaDrop
+)
+
+func ParseQuotaAction(action string) (QuotaAction, error) {
+	switch action {
+	case "park":
+		return QuotaPark, nil
+	case "drop":
+		return QuotaDrop, nil
+	default:
+		return QuotaPark, fmt.Errorf("Unknown quota action: %q", action)
+	}
+}
+
+func (a QuotaAction) String() string {
+	if a == QuotaDrop {
+		return "dropped"
+	}
+	return "parked"
+}
+
+// Quota limits the number of pages and bytes fetched. Zero means unlimited.
+type Quota struct {
+	MaxPages uint64
+	MaxBytes uint64
+}
+
+func (q Quota) exceededBy(stats QuotaStats) bool {
+	return (q.MaxPages > 0 && stats.Pages >= q.MaxPages) ||
+		(q.MaxBytes > 0 && stats.Bytes >= q.MaxBytes)
+}
+
+func (q Quota) isSet() bool {
+	return q.MaxPages > 0 || q.MaxBytes > 0
+}
+
+type QuotaStats struct {
+	Pages uint64 `json:"pages"`
+	Bytes uint64 `json:"bytes"`
+}
+
+type QuotaStorage storage.Storage[QuotaStats]
+
+type quotas struct {
+	mu      sync.Mutex
+	storage QuotaStorage
+	// over tells whether a key is over its quota, for the keys looked up so
+	// far, so that checking a link doesn't read the storage.
+	over map[string]bool
+
+	host   Quota
+	domain Quota
+}
+
+func newQuotas(storage QuotaStorage, host Quota, domain Quota) *quotas {
+	return &quotas{
+		storage: storage,
+		over:    make(map[string]bool),
+		host:    host,
+		domain:  domain,
+	}
+}
+
+// lookupDomain returns the value set for the host, or else the one set for its
+// registered domain.
+func lookupDomain[V any](values map[string]V, host string) (V, bool) {
+	if v, ok := values[host]; ok {
+		return v, true
+	}
+	v, ok := values[hostname.RegisteredDomain(host)]
+	return v, ok
+}
+
+func hostQuotaKey(host string) string {
+	return "host:" + host
+}
+
+func domainQuotaKey(domain string) string {
+	return "domain:" + domain
+}
+
+// add accounts a fetched page of the given size and reports which of the
+// host and domain quotas the page made exceeded. Stats are only kept for the
+// quotas that are set.
+func (q *quotas) add(host string, size int64) (hostExceeded bool, domainExceeded bool, err error) {
+	if !q.host.isSet() && !q.domain.isSet() {
+		return false, false, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.host.isSet() {
+		hostExceeded, err = q.addTo(hostQuotaKey(host), q.host, size)
+		if err != nil {
+			return false, false, err
+		}
+	}
+
+	if q.domain.isSet() {
+		domainExceeded, err = q.addTo(domainQuotaKey(hostname.RegisteredDomain(host)), q.domain, size)
+		if err != nil {
+			return false, false, err
+		}
+	}
+
+	return hostExceeded, domainExceeded, nil
+}
+
+func (q *quotas) addTo(key string, quota Quota, size int64) (bool, error) {
+	stats, err := q.storage.Get(key)
+	if err != nil && err != storage.NoSuchKeyError {
+		return false, err
+	}
+
+	wasOver := quota.exceededBy(stats)
+	stats.Pages++
+	if size > 0 {
+		stats.Bytes += uint64(size)
+	}
+
+	if err := q.storage.Put(key, stats); err != nil {
+		return false, err
+	}
+	q.over[key] = quota.exceededBy(stats)
+	return q.over[key] && !wasOver, nil
+}
+
+// exceeded reports whether the host or its registered domain is over quota.
+func (q *quotas) exceeded(host string) (bool, error) {
+	if !q.host.isSet() && !q.domain.isSet() {
+		return false, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.host.isSet() {
+		over, err := q.isOver(hostQuotaKey(host), q.host)
+		if err != nil || over {
+			return over, err
+		}
+	}
+
+	if q.domain.isSet() {
+		return q.isOver(domainQuotaKey(hostname.RegisteredDomain(host)), q.domain)
+	}
+	return false, nil
+}
+
+func (q *quotas) isOver(key string, quota Quota) (bool, error) {
+	if over, ok := q.over[key]; ok {
+		return over, nil
+	}
+
+	stats, err := q.storage.Get(key)
+	if err != nil && err != storage.NoSuchKeyError {
+		return false, err
+	}
+	q.over[key] = quota.exceededBy(stats)
+	return q.over[key], nil
+}
+
+// get returns the stats kept under key, zero if there are none.
+func (q *quotas) get(key string) (QuotaStats, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats, err := q.storage.Get(key)
+	if err != nil && err != storage.NoSuchKeyError {
+		return QuotaStats{}, err
+	}
+	return stats, nil
+}
+
+// merge adds stats to the ones kept under key.
+func (q *quotas) merge(key string, stats QuotaStats) error {
+	if stats == (QuotaStats{}) {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current, err := q.storage.Get(key)
+	if err != nil && err != storage.NoSuchKeyError {
+		return err
+	}
+	current.Pages += stats.Pages
+	current.Bytes += stats.Bytes
+	delete(q.over, key)
+	return q.storage.Put(key, current)
+}
@@ -0,0 +1,111 @@
+package frontier
+
+import (
+	"net/url"
+	"testing"
+
+	boom "github.com/tylertreat/BoomFilters"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func newTestFrontier(opts ...BfFrontierOption) *BfFrontier {
+	return NewBfFrontier(InMemoryQueueProvider{}, inmem.NewInMemoryStorage[*boom.ScalableBloomFilter](), opts...)
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return u
+}
+
+func TestHostQuota(t *testing.T) {
+	f := newTestFrontier(WithHostQuota(Quota{MaxPages: 2}), WithQuotaAction(QuotaDrop))
+
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2", "http://a.com/3", "http://a.com/4"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	f.MarkSuccessful(mustParse(t, "http://a.com/1"), UrlMeta{}, FetchInfo{Size: 10})
+	if f.queueMap["a.com"].IsRetired() {
+		t.Fatalf("Queue retired before reaching its quota")
+	}
+
+	f.MarkSuccessful(mustParse(t, "http://a.com/2"), UrlMeta{}, FetchInfo{Size: 10})
+	queue := f.queueMap["a.com"]
+	if !queue.IsRetired() {
+		t.Fatalf("Queue not retired after reaching its quota")
+	}
+	if !queue.IsEmpty() {
+		t.Fatalf("Urls of a dropped queue were kept. Have: %d, want: 0", queue.Len())
+	}
+
+	f.Put(mustParse(t, "http://a.com/5"), UrlMeta{})
+	if !queue.IsEmpty() {
+		t.Fatalf("Url enqueued for a host over quota")
+	}
+}
+
+func TestDomainQuota(t *testing.T) {
+	stats := inmem.NewInMemoryStorage[QuotaStats]()
+	f := newTestFrontier(WithDomainQuota(Quota{MaxBytes: 100}), WithQuotaStorage(stats))
+
+	for _, raw := range []string{"http://a.example.com/1", "http://b.example.com/1", "http://other.com/1"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	f.MarkSuccessful(mustParse(t, "http://a.example.com/0"), UrlMeta{}, FetchInfo{Size: 100})
+
+	for id, want := range map[string]bool{"a.example.com": true, "b.example.com": true, "other.com": false} {
+		if have := f.queueMap[id].IsRetired(); have != want {
+			t.Errorf("Unexpected retired state of %s. Have: %t, want: %t", id, have, want)
+		}
+	}
+
+	if f.queueMap["b.example.com"].IsEmpty() {
+		t.Errorf("Urls of a parked queue were dropped")
+	}
+	// the domain is counted once, when it goes over its quota
+	if _, domainOver, _ := f.quotas.add("b.example.com", 1); domainOver {
+		t.Errorf("Domain already over quota was reported again")
+	}
+
+	// without a host quota, only the domain stats are kept
+	if all, _ := stats.GetAll(); len(all) != 1 {
+		t.Errorf("Unexpected quota stats: %v", all)
+	}
+}
+
+type countingQuotaStorage struct {
+	QuotaStorage
+	gets int
+}
+
+func (s *countingQuotaStorage) Get(key string) (QuotaStats, error) {
+	s.gets++
+	return s.QuotaStorage.Get(key)
+}
+
+func TestQuotaLookupCached(t *testing.T) {
+	stats := &countingQuotaStorage{QuotaStorage: inmem.NewInMemoryStorage[QuotaStats]()}
+	f := newTestFrontier(WithHostQuota(Quota{MaxPages: 1}), WithQuotaStorage(stats))
+
+	for _, raw := range []string{"http://a.com/1", "http://a.com/2", "http://a.com/3"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if stats.gets != 1 {
+		t.Fatalf("Quota stats were read for every url. Have: %d reads, want: 1", stats.gets)
+	}
+
+	f.MarkSuccessful(mustParse(t, "http://a.com/1"), UrlMeta{}, FetchInfo{})
+	if over, _ := f.quotas.exceeded("a.com"); !over {
+		t.Fatalf("Host over quota was not seen as such")
+	}
+}
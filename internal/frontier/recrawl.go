@@ -0,0 +1,309 @@
+package frontier
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+// RecrawlState is what is known about how often a url changes.
+type RecrawlState struct {
+	Digest      string        `json:"digest,omitempty"`
+	Interval    time.Duration `json:"interval"`
+	LastFetched time.Time     `json:"last_fetched"`
+	Checks      uint32        `json:"checks"`
+	Changes     uint32        `json:"changes"`
+	// Failures counts the failed fetches since the last successful one.
+	Failures uint32 `json:"failures,omitempty"`
+	// Next is when the url is scheduled to be visited. Schedule entries at
+	// other times were superseded.
+	Next time.Time `json:"next"`
+}
+
+type RecrawlStorage storage.Storage[RecrawlState]
+
+// ScheduledUrl is a url due for a revisit at At.
+type ScheduledUrl struct {
+	Url string
+	At  time.Time
+	UrlMeta
+}
+
+// Score orders the schedule so that the earliest visit comes first.
+func (s ScheduledUrl) Score() uint32 {
+	at := s.At.Unix()
+	if at < 0 {
+		at = 0
+	}
+	if at > math.MaxUint32 {
+		at = math.MaxUint32
+	}
+	return math.MaxUint32 - uint32(at)
+}
+
+// RecrawlSchedule is a time ordered store of scheduled urls: Peek and Pop
+// return the one due first.
+type RecrawlSchedule storage.Queue[ScheduledUrl]
+
+// RecrawlPolicy bounds the revisit interval of a url.
+type RecrawlPolicy struct {
+	Initial time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+func DefaultRecrawlPolicy() RecrawlPolicy {
+	return RecrawlPolicy{
+		Initial: 24 * time.Hour,
+		Min:     time.Hour,
+		Max:     30 * 24 * time.Hour,
+	}
+}
+
+// next estimates the revisit interval after a fetch. The interval halves
+// when the content changed and grows by half when it did not. A first
+// fetch starts from a tenth of the time since Last-Modified, or the sitemap
+// lastmod, if known.
+// The interval is never shorter than the freshness lifetime the server
+// announced with Cache-Control or Expires.
+func (p RecrawlPolicy) next(prev RecrawlState, seen bool, info FetchInfo, now time.Time) RecrawlState {
+	state := RecrawlState{
+		Digest:      info.Digest,
+		LastFetched: now,
+		Checks:      prev.Checks + 1,
+		Changes:     prev.Changes,
+	}
+
+	switch {
+	case !seen:
+		state.Interval = p.Initial
+		if !info.LastModified.IsZero() && info.LastModified.Before(now) {
+			state.Interval = now.Sub(info.LastModified) / 10
+		}
+	case info.Digest != prev.Digest:
+		state.Changes++
+		state.Interval = prev.Interval / 2
+	default:
+		state.Interval = prev.Interval + prev.Interval/2
+	}
+
+	if info.MaxAge > state.Interval {
+		state.Interval = info.MaxAge
+	}
+	if state.Interval < p.Min {
+		state.Interval = p.Min
+	}
+	if state.Interval > p.Max {
+		state.Interval = p.Max
+	}
+	return state
+}
+
+// backoff returns the delay of the next visit after the given number of
+// failed fetches in a row. It starts at the minimum interval and doubles with
+// every failure, up to the maximum.
+func (p RecrawlPolicy) backoff(failures uint32) time.Duration {
+	delay := p.Min
+	for i := uint32(1); i < failures && delay < p.Max; i++ {
+		delay *= 2
+	}
+	if delay > p.Max {
+		delay = p.Max
+	}
+	return delay
+}
+
+type recrawl struct {
+	policy   RecrawlPolicy
+	states   RecrawlStorage
+	schedule RecrawlSchedule
+
+	mu sync.Mutex
+}
+
+// update records a fetch of the url at now and schedules its next visit.
+func (r *recrawl) update(u string, meta UrlMeta, info FetchInfo, now time.Time) (RecrawlState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, err := r.states.Get(u)
+	if err != nil && err != storage.NoSuchKeyError {
+		return RecrawlState{}, err
+	}
+
+	if info.LastModified.IsZero() {
+		info.LastModified = meta.LastMod
+	}
+	state := r.policy.next(prev, err == nil, info, now)
+	state.Next = now.Add(state.Interval)
+	if err := r.states.Put(u, state); err != nil {
+		return RecrawlState{}, err
+	}
+	return state, r.scheduleAt(u, meta, state.Next)
+}
+
+// failed records a failed fetch of the url at now and schedules another
+// visit after a backoff. Urls that were never fetched successfully are not
+// recrawled; failed reports whether the url was rescheduled.
+func (r *recrawl) failed(u string, meta UrlMeta, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := r.states.Get(u)
+	if err != nil {
+		if err == storage.NoSuchKeyError {
+			return false, nil
+		}
+		return false, err
+	}
+
+	state.Failures++
+	state.Next = now.Add(r.policy.backoff(state.Failures))
+	if err := r.states.Put(u, state); err != nil {
+		return false, err
+	}
+	return true, r.scheduleAt(u, meta, state.Next)
+}
+
+// modified moves the next visit of the url to now if a sitemap says it
+// changed after it was last fetched. It reports whether it did.
+func (r *recrawl) modified(u string, meta UrlMeta, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := r.states.Get(u)
+	if err != nil {
+		if err == storage.NoSuchKeyError {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// a visit that is already due will see the change
+	if !meta.LastMod.After(state.LastFetched) || !state.Next.After(now) {
+		return false, nil
+	}
+
+	state.Next = now
+	if err := r.states.Put(u, state); err != nil {
+		return false, err
+	}
+	return true, r.scheduleAt(u, meta, now)
+}
+
+// scheduleAt schedules a revisit of the url. It has to be called with mu held.
+func (r *recrawl) scheduleAt(u string, meta UrlMeta, at time.Time) error {
+	meta.Revisit = true
+	meta.Inlinks = 0
+	meta.LastMod = time.Time{}
+	return r.schedule.Push(ScheduledUrl{
+		Url:     u,
+		At:      at,
+		UrlMeta: meta,
+	})
+}
+
+// visitDue hands the urls whose visit is due at now to visit, earliest first,
+// and takes each off the schedule only once visit returned. It stops at the
+// first error and keeps that url scheduled, to be retried by the next call.
+// Superseded entries are dropped without a visit.
+func (r *recrawl) visitDue(now time.Time, limit int, visit func(ScheduledUrl) error) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	visited := 0
+	for visited < limit {
+		next, err := r.schedule.Peek()
+		if err != nil {
+			if err == storage.NoNextItem {
+				break
+			}
+			return visited, err
+		}
+
+		if next.At.After(now) {
+			break
+		}
+
+		superseded, err := r.superseded(next)
+		if err != nil {
+			return visited, err
+		}
+		if superseded {
+			if _, err := r.schedule.Pop(); err != nil {
+				return visited, err
+			}
+			continue
+		}
+
+		if err := visit(next); err != nil {
+			return visited, err
+		}
+		if _, err := r.schedule.Pop(); err != nil {
+			return visited, err
+		}
+		visited++
+	}
+	return visited, nil
+}
+
+// superseded reports whether the url was scheduled again at another time
+// after the entry was pushed. It has to be called with mu held.
+func (r *recrawl) superseded(s ScheduledUrl) (bool, error) {
+	state, err := r.states.Get(s.Url)
+	if err != nil {
+		if err == storage.NoSuchKeyError {
+			return false, nil
+		}
+		return false, err
+	}
+	// states from before Next was kept don't know
+	return !state.Next.IsZero() && !state.Next.Equal(s.At), nil
+}
+
+// memorySchedule is an in-memory RecrawlSchedule.
+type memorySchedule struct {
+	pq *inmem.PriorityQueue[ScheduledUrl]
+}
+
+func NewMemorySchedule() RecrawlSchedule {
+	return &memorySchedule{pq: inmem.NewPriorityQueue[ScheduledUrl]()}
+}
+
+func (m *memorySchedule) Push(s ScheduledUrl) error {
+	m.pq.Push(s, int(s.At.UnixMilli()))
+	return nil
+}
+
+func (m *memorySchedule) Peek() (ScheduledUrl, error) {
+	s, _, ok := m.pq.Peek()
+	if !ok {
+		return ScheduledUrl{}, storage.NoNextItem
+	}
+	return s, nil
+}
+
+func (m *memorySchedule) Pop() (ScheduledUrl, error) {
+	if m.pq.Length() == 0 {
+		return ScheduledUrl{}, storage.NoNextItem
+	}
+	s, _, _ := m.pq.Pop()
+	return s, nil
+}
+
+// PeekN returns up to n urls in the order they are due.
+func (m *memorySchedule) PeekN(n int) ([]ScheduledUrl, error) {
+	all := m.pq.Values()
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].At.Before(all[j].At)
+	})
+	return all[:min(n, len(all))], nil
+}
+
+func (m *memorySchedule) Len() int {
+	return m.pq.Length()
+}
@@ -0,0 +1,280 @@
+package frontier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+// Scheduler decides which of the active host queues is crawled next. Queues
+// are pushed with the time their politeness allows the next request at, and
+// popped with the time they were pushed with; a queue popped early is waited
+// for by the worker. Which queues are active is up to the frontier, through
+// maxActiveQueues and session budgets.
+//
+// A queue may be pushed again before it is popped. Schedulers are not safe
+// for concurrent use.
+type Scheduler interface {
+	Push(id string, at time.Time)
+	Pop(now time.Time) (id string, at time.Time, ok bool)
+	Len() int
+}
+
+// DeadlineScheduler is a Scheduler that is told about due revisits.
+type DeadlineScheduler interface {
+	Scheduler
+	// Due tells that a revisit of the queue was queued that is due at the
+	// given time.
+	Due(id string, at time.Time)
+	// Served tells that a revisit of the queue was handed out.
+	Served(id string)
+}
+
+// NewScheduler returns the scheduler of the given name. Weights are only used
+// by the weighted scheduler.
+func NewScheduler(name string, weights map[string]float64) (Scheduler, error) {
+	switch name {
+	case "", "time":
+		return NewTimeScheduler(), nil
+	case "round_robin":
+		return NewRoundRobinScheduler(), nil
+	case "weighted":
+		return NewWeightedScheduler(weights), nil
+	case "deadline":
+		return NewDeadlineScheduler(), nil
+	default:
+		return nil, fmt.Errorf("Unknown scheduler: %q", name)
+	}
+}
+
+type scheduledQueue struct {
+	id string
+	at time.Time
+}
+
+func (q scheduledQueue) time() time.Time {
+	return q.at
+}
+
+// earliest returns the index of the entry with the earliest time.
+func earliest[E interface{ time() time.Time }](entries []E) int {
+	first := 0
+	for i, e := range entries {
+		if e.time().Before(entries[first].time()) {
+			first = i
+		}
+	}
+	return first
+}
+
+// timeScheduler crawls the queue whose next access is the earliest.
+type timeScheduler struct {
+	pq *inmem.PriorityQueue[string]
+}
+
+func NewTimeScheduler() Scheduler {
+	return &timeScheduler{pq: inmem.NewPriorityQueue[string]()}
+}
+
+func (s *timeScheduler) Push(id string, at time.Time) {
+	s.pq.Push(id, int(at.UnixMilli()))
+}
+
+func (s *timeScheduler) Pop(now time.Time) (string, time.Time, bool) {
+	if s.pq.Length() == 0 {
+		return "", time.Time{}, false
+	}
+	id, priority, ok := s.pq.Pop()
+	return id, time.UnixMilli(int64(priority)), ok
+}
+
+func (s *timeScheduler) Len() int {
+	return s.pq.Length()
+}
+
+// roundRobinScheduler crawls the queues strictly in turn, in the order they
+// were pushed, even if a later one is ready earlier.
+type roundRobinScheduler struct {
+	entries []scheduledQueue
+}
+
+func NewRoundRobinScheduler() Scheduler {
+	return &roundRobinScheduler{}
+}
+
+func (s *roundRobinScheduler) Push(id string, at time.Time) {
+	s.entries = append(s.entries, scheduledQueue{id: id, at: at})
+}
+
+func (s *roundRobinScheduler) Pop(now time.Time) (string, time.Time, bool) {
+	if len(s.entries) == 0 {
+		return "", time.Time{}, false
+	}
+	e := s.entries[0]
+	s.entries = s.entries[1:]
+	return e.id, e.at, true
+}
+
+func (s *roundRobinScheduler) Len() int {
+	return len(s.entries)
+}
+
+type weightedQueue struct {
+	scheduledQueue
+	finish float64
+}
+
+// weightedScheduler shares the crawl among the queues that are ready in
+// proportion to their weights, by weighted fair queueing: every turn of a
+// queue costs it the inverse of its weight in virtual time, and the ready
+// queue that finishes its turn first is crawled. If no queue is ready, the
+// earliest one is.
+type weightedScheduler struct {
+	weights map[string]float64
+
+	entries []weightedQueue
+	virtual float64
+	finish  map[string]float64
+}
+
+// NewWeightedScheduler returns a weighted fair scheduler. Weights are given by
+// host or by registered domain; a host without a weight has a weight of 1.
+func NewWeightedScheduler(weights map[string]float64) Scheduler {
+	return &weightedScheduler{
+		weights: weights,
+		finish:  make(map[string]float64),
+	}
+}
+
+func (s *weightedScheduler) weight(id string) float64 {
+	if w, ok := lookupDomain(s.weights, id); ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+func (s *weightedScheduler) Push(id string, at time.Time) {
+	finish := max(s.virtual, s.finish[id]) + 1/s.weight(id)
+	s.finish[id] = finish
+	s.entries = append(s.entries, weightedQueue{
+		scheduledQueue: scheduledQueue{id: id, at: at},
+		finish:         finish,
+	})
+}
+
+func (s *weightedScheduler) Pop(now time.Time) (string, time.Time, bool) {
+	if len(s.entries) == 0 {
+		return "", time.Time{}, false
+	}
+
+	next := -1
+	for i, e := range s.entries {
+		if e.at.After(now) {
+			continue
+		}
+		if next < 0 || e.finish < s.entries[next].finish {
+			next = i
+		}
+	}
+	if next < 0 {
+		next = earliest(s.entries)
+	}
+
+	e := s.entries[next]
+	s.entries = append(s.entries[:next], s.entries[next+1:]...)
+	s.virtual = max(s.virtual, e.finish)
+	if len(s.entries) == 0 {
+		// every finish time is behind the virtual time now
+		clear(s.finish)
+	}
+	return e.id, e.at, true
+}
+
+func (s *weightedScheduler) Len() int {
+	return len(s.entries)
+}
+
+type deadline struct {
+	at      time.Time
+	pending int
+}
+
+// deadlineScheduler crawls the ready queue whose earliest due revisit is the
+// most overdue first, then the other ready queues by their next access. If no
+// queue is ready, the earliest one is.
+type deadlineScheduler struct {
+	entries   []scheduledQueue
+	deadlines map[string]deadline
+}
+
+func NewDeadlineScheduler() DeadlineScheduler {
+	return &deadlineScheduler{deadlines: make(map[string]deadline)}
+}
+
+func (s *deadlineScheduler) Due(id string, at time.Time) {
+	d := s.deadlines[id]
+	if d.pending == 0 || at.Before(d.at) {
+		d.at = at
+	}
+	d.pending++
+	s.deadlines[id] = d
+}
+
+func (s *deadlineScheduler) Served(id string) {
+	d, ok := s.deadlines[id]
+	if !ok {
+		return
+	}
+	// the deadline of the oldest revisit is kept until all revisits of
+	// the queue were handed out
+	if d.pending--; d.pending > 0 {
+		s.deadlines[id] = d
+	} else {
+		delete(s.deadlines, id)
+	}
+}
+
+func (s *deadlineScheduler) Push(id string, at time.Time) {
+	s.entries = append(s.entries, scheduledQueue{id: id, at: at})
+}
+
+// before reports whether the ready queue a goes before the ready queue b.
+func (s *deadlineScheduler) before(a, b scheduledQueue) bool {
+	da, hasA := s.deadlines[a.id]
+	db, hasB := s.deadlines[b.id]
+	switch {
+	case hasA && hasB && !da.at.Equal(db.at):
+		return da.at.Before(db.at)
+	case hasA != hasB:
+		return hasA
+	}
+	return a.at.Before(b.at)
+}
+
+func (s *deadlineScheduler) Pop(now time.Time) (string, time.Time, bool) {
+	if len(s.entries) == 0 {
+		return "", time.Time{}, false
+	}
+
+	next := -1
+	for i, e := range s.entries {
+		if e.at.After(now) {
+			continue
+		}
+		if next < 0 || s.before(e, s.entries[next]) {
+			next = i
+		}
+	}
+	if next < 0 {
+		next = earliest(s.entries)
+	}
+
+	e := s.entries[next]
+	s.entries = append(s.entries[:next], s.entries[next+1:]...)
+	return e.id, e.at, true
+}
+
+func (s *deadlineScheduler) Len() int {
+	return len(s.entries)
+}
@@ -0,0 +1,138 @@
+package frontier
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when a simulation moves it.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// simulate crawls with a single worker on a fake clock: every url is fetched
+// at the time the frontier hands it out for and takes the response time of its
+// host. It returns the hosts of the first n fetched urls, in fetch order.
+func simulate(t *testing.T, f *BfFrontier, clock *fakeClock, ttr map[string]time.Duration, n int) []string {
+	var fetched []string
+	for len(fetched) < n {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		u, meta, at, err := f.Get(ctx)
+		cancel()
+		if err == context.DeadlineExceeded {
+			t.Fatalf("Frontier ran dry after %d urls", len(fetched))
+		}
+		if err != nil {
+			// the queue ran empty and was swapped out
+			continue
+		}
+
+		if at.After(clock.now) {
+			clock.now = at
+		}
+		host := toId(u)
+		clock.now = clock.now.Add(ttr[host])
+		if err := f.MarkSuccessful(u, meta, FetchInfo{TTR: ttr[host]}); err != nil {
+			t.Fatal(err.Error())
+		}
+		fetched = append(fetched, host)
+	}
+	return fetched
+}
+
+func newSimulation(t *testing.T, scheduler Scheduler, hosts []string, urls int, opts ...BfFrontierOption) (*BfFrontier, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts = append(opts, WithScheduler(scheduler), WithClock(clock.Now), WithSessionBudget(1000))
+	f := newTestFrontier(opts...)
+
+	for i := 0; i < urls; i++ {
+		for _, host := range hosts {
+			if err := f.Put(mustParse(t, fmt.Sprintf("http://%s/%d", host, i)), UrlMeta{}); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+	return f, clock
+}
+
+func countHosts(fetched []string) map[string]int {
+	counts := make(map[string]int)
+	for _, host := range fetched {
+		counts[host]++
+	}
+	return counts
+}
+
+func TestTimeScheduler(t *testing.T) {
+	ttr := map[string]time.Duration{"fast.com": 10 * time.Millisecond, "slow.com": 100 * time.Millisecond}
+	f, clock := newSimulation(t, NewTimeScheduler(), []string{"fast.com", "slow.com"}, 50)
+
+	counts := countHosts(simulate(t, f, clock, ttr, 30))
+	if counts["fast.com"] < 4*counts["slow.com"] {
+		t.Fatalf("Fast host was not crawled more often: %v", counts)
+	}
+}
+
+func TestRoundRobinScheduler(t *testing.T) {
+	ttr := map[string]time.Duration{"fast.com": 10 * time.Millisecond, "slow.com": 100 * time.Millisecond}
+	f, clock := newSimulation(t, NewRoundRobinScheduler(), []string{"fast.com", "slow.com"}, 50)
+
+	fetched := simulate(t, f, clock, ttr, 30)
+	for i := 1; i < len(fetched); i++ {
+		if fetched[i] == fetched[i-1] {
+			t.Fatalf("Host crawled twice in a row at %d: %v", i, fetched)
+		}
+	}
+}
+
+func TestWeightedScheduler(t *testing.T) {
+	scheduler := NewWeightedScheduler(map[string]float64{"heavy.com": 3})
+	f, clock := newSimulation(t, scheduler, []string{"heavy.com", "www.light.com"}, 50)
+
+	counts := countHosts(simulate(t, f, clock, nil, 40))
+	if counts["heavy.com"] < 28 || counts["heavy.com"] > 32 {
+		t.Fatalf("Crawl was not shared by weight: %v", counts)
+	}
+
+	if w := scheduler.(*weightedScheduler).weight("www.heavy.com"); w != 3 {
+		t.Fatalf("Weight of the registered domain was not used: %v", w)
+	}
+}
+
+func TestDeadlineScheduler(t *testing.T) {
+	f, clock := newSimulation(t, NewDeadlineScheduler(), []string{"a.com"}, 3, WithRecrawl(DefaultRecrawlPolicy()))
+
+	for host, due := range map[string]time.Duration{"b.com": time.Minute, "c.com": time.Hour} {
+		f.recrawl.schedule.Push(ScheduledUrl{
+			Url:     "http://" + host + "/",
+			At:      clock.now.Add(-due),
+			UrlMeta: UrlMeta{Revisit: true},
+		})
+	}
+	f.queueDue(clock.now)
+
+	fetched := simulate(t, f, clock, nil, 4)
+	want := []string{"c.com", "b.com", "a.com", "a.com"}
+	for i := range want {
+		if fetched[i] != want[i] {
+			t.Fatalf("Overdue revisits were not crawled first. Have: %v, want: %v", fetched, want)
+		}
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	for _, name := range []string{"", "time", "round_robin", "weighted", "deadline"} {
+		if _, err := NewScheduler(name, nil); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, err := NewScheduler("lottery", nil); err == nil {
+		t.Fatalf("Unknown scheduler was accepted")
+	}
+}
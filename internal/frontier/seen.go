@@ -0,0 +1,262 @@
+package frontier
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sync"
+
+	boom "github.com/tylertreat/BoomFilters"
+	"github.com/xunterr/aracno/internal/storage"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+// seenSet remembers which urls of each host were processed.
+type seenSet interface {
+	add(host string, url []byte) error
+	contains(host string, url []byte) (bool, error)
+	// forget drops all urls of the host.
+	forget(host string) error
+	// export and restore move the urls of a host to another node.
+	export(host string) ([]byte, error)
+	restore(host string, data []byte) error
+	flush() error
+}
+
+const fingerprintSize = 16
+
+// fingerprint is the 128-bit FNV-1a hash of a url.
+func fingerprint(url []byte) []byte {
+	h := fnv.New128a()
+	h.Write(url)
+	return h.Sum(nil)
+}
+
+func seenPrefix(host string) string {
+	return host + "\x00"
+}
+
+// hostFilter is the in-memory view of the seen urls of a host.
+type hostFilter struct {
+	// fingerprints holds the fingerprints of the set. It only tells for sure
+	// that a url was not seen.
+	fingerprints *boom.ScalableBloomFilter
+	// legacy is the bloom filter of the host from before the exact set, if
+	// there is one.
+	legacy *boom.ScalableBloomFilter
+}
+
+type exactShard struct {
+	mu      sync.Mutex
+	filters *inmem.LruCache[*hostFilter]
+	// loading holds the hosts whose filter is being built, with a channel
+	// that is closed when it is done.
+	loading map[string]chan struct{}
+}
+
+// exactSeen keeps the fingerprint of every seen url, prefixed by its host, so
+// it doesn't lose urls to false positives. The filter of a host is built from
+// the set on its first lookup and answers most lookups of new urls without
+// reading the set. Hosts are spread over shards with a lock and a filter
+// cache each, and filters are built outside of the lock.
+//
+// Urls seen before the switch from bloom filters are still found in the old
+// filters, with their false positive rate.
+type exactSeen struct {
+	set    storage.Set
+	legacy BloomStorage
+	shards [bloomShards]*exactShard
+}
+
+func newExactSeen(set storage.Set, legacy BloomStorage, cacheSize uint) *exactSeen {
+	s := &exactSeen{
+		set:    set,
+		legacy: legacy,
+	}
+	for i := range s.shards {
+		s.shards[i] = &exactShard{
+			filters: inmem.NewLruCache[*hostFilter](max(cacheSize/bloomShards, 1)),
+			loading: make(map[string]chan struct{}),
+		}
+	}
+	return s
+}
+
+func (s *exactSeen) shard(host string) *exactShard {
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return s.shards[h.Sum32()%bloomShards]
+}
+
+// filter returns the filter of the host, building it if needed. Only one
+// lookup builds the filter of a host; the others wait for it.
+func (s *exactSeen) filter(shard *exactShard, host string) (*hostFilter, error) {
+	shard.mu.Lock()
+	for {
+		if filter, err := shard.filters.Get(host); err == nil {
+			shard.mu.Unlock()
+			return filter, nil
+		}
+		done, ok := shard.loading[host]
+		if !ok {
+			break
+		}
+		shard.mu.Unlock()
+		<-done
+		shard.mu.Lock()
+	}
+	done := make(chan struct{})
+	shard.loading[host] = done
+	shard.mu.Unlock()
+
+	filter, err := s.build(host)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.loading, host)
+	close(done)
+	if err != nil {
+		return nil, err
+	}
+	return filter, shard.filters.Put(host, filter)
+}
+
+// build reads the filter of the host from the set and the legacy storage.
+func (s *exactSeen) build(host string) (*hostFilter, error) {
+	filter := &hostFilter{
+		fingerprints: boom.NewDefaultScalableBloomFilter(0.01),
+	}
+
+	prefix := seenPrefix(host)
+	err := s.set.Scan(prefix, func(key string) error {
+		filter.fingerprints.Add([]byte(key[len(prefix):]))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	legacy, err := s.legacy.Get(host)
+	if err != nil && err != storage.NoSuchKeyError {
+		return nil, err
+	}
+	filter.legacy = legacy
+	return filter, nil
+}
+
+// evict drops the cached filter of the host, after its urls changed in the
+// storage.
+func (s *exactSeen) evict(host string) {
+	shard := s.shard(host)
+	shard.mu.Lock()
+	shard.filters.Delete(host)
+	shard.mu.Unlock()
+}
+
+func (s *exactSeen) add(host string, url []byte) error {
+	fp := fingerprint(url)
+
+	// the set is written first, so that a filter built meanwhile has the url
+	if err := s.set.Add(seenPrefix(host) + string(fp)); err != nil {
+		return err
+	}
+
+	shard := s.shard(host)
+	filter, err := s.filter(shard, host)
+	if err != nil {
+		return err
+	}
+
+	shard.mu.Lock()
+	filter.fingerprints.Add(fp)
+	shard.mu.Unlock()
+	return nil
+}
+
+func (s *exactSeen) contains(host string, url []byte) (bool, error) {
+	fp := fingerprint(url)
+
+	shard := s.shard(host)
+	filter, err := s.filter(shard, host)
+	if err != nil {
+		return false, err
+	}
+
+	shard.mu.Lock()
+	maybe := filter.fingerprints.Test(fp)
+	legacy := filter.legacy != nil && filter.legacy.Test(url)
+	shard.mu.Unlock()
+
+	if legacy {
+		return true, nil
+	}
+	if !maybe {
+		return false, nil
+	}
+	return s.set.Has(seenPrefix(host) + string(fp))
+}
+
+func (s *exactSeen) forget(host string) error {
+	defer s.evict(host)
+
+	if err := s.set.DeletePrefix(seenPrefix(host)); err != nil {
+		return err
+	}
+
+	err := s.legacy.Delete(host)
+	if err == storage.NoSuchKeyError {
+		return nil
+	}
+	return err
+}
+
+// export returns the fingerprints of the host, one after another. Legacy
+// filters are not exported.
+func (s *exactSeen) export(host string) ([]byte, error) {
+	prefix := seenPrefix(host)
+
+	var data []byte
+	err := s.set.Scan(prefix, func(key string) error {
+		data = append(data, key[len(prefix):]...)
+		return nil
+	})
+	return data, err
+}
+
+// restore adds the exported fingerprints to the set of the host.
+func (s *exactSeen) restore(host string, data []byte) error {
+	defer s.evict(host)
+
+	prefix := seenPrefix(host)
+	for i := 0; i+fingerprintSize <= len(data); i += fingerprintSize {
+		if err := s.set.Add(prefix + string(data[i:i+fingerprintSize])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreLegacy keeps an exported bloom filter of the host as its legacy
+// filter, unless it already has one. It reports whether the filter was kept.
+func (s *exactSeen) restoreLegacy(host string, data []byte) (bool, error) {
+	filter := boom.NewDefaultScalableBloomFilter(0.01)
+	if _, err := filter.ReadFrom(bytes.NewReader(data)); err != nil {
+		return false, err
+	}
+
+	shard := s.shard(host)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, err := s.legacy.Get(host); err != storage.NoSuchKeyError {
+		return false, err
+	}
+	if err := s.legacy.Put(host, filter); err != nil {
+		return false, err
+	}
+	shard.filters.Delete(host)
+	return true, nil
+}
+
+func (s *exactSeen) flush() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package frontier
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	boom "github.com/tylertreat/BoomFilters"
+	"github.com/xunterr/aracno/internal/storage/inmem"
+)
+
+func TestExactSeen(t *testing.T) {
+	legacy := inmem.NewInMemoryStorage[*boom.ScalableBloomFilter]()
+	old := boom.NewDefaultScalableBloomFilter(0.01)
+	old.Add([]byte("http://a.com/old"))
+	legacy.Put("a.com", old)
+
+	set := inmem.NewSet()
+	seen := newExactSeen(set, legacy, 4096)
+	for _, u := range []string{"http://a.com/1", "http://b.com/1"} {
+		if err := seen.add(toId(mustParse(t, u)), []byte(u)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	check := func(seen seenSet, want map[string]bool) {
+		t.Helper()
+		for u, want := range want {
+			have, err := seen.contains(toId(mustParse(t, u)), []byte(u))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if have != want {
+				t.Fatalf("Unexpected seen state of %s. Have: %t, want: %t", u, have, want)
+			}
+		}
+	}
+
+	check(seen, map[string]bool{
+		"http://a.com/1":   true,
+		"http://a.com/2":   false,
+		"http://a.com/old": true,
+		"http://b.com/1":   true,
+		"http://b.com/old": false,
+	})
+
+	// the set is read back after a restart
+	check(newExactSeen(set, legacy, 4096), map[string]bool{"http://a.com/1": true})
+
+	data, err := seen.export("a.com")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other := newExactSeen(inmem.NewSet(), inmem.NewInMemoryStorage[*boom.ScalableBloomFilter](), 4096)
+	if err := other.restore("a.com", data); err != nil {
+		t.Fatal(err.Error())
+	}
+	check(other, map[string]bool{"http://a.com/1": true, "http://a.com/old": false})
+
+	if err := seen.forget("a.com"); err != nil {
+		t.Fatal(err.Error())
+	}
+	check(seen, map[string]bool{
+		"http://a.com/1":   false,
+		"http://a.com/old": false,
+		"http://b.com/1":   true,
+	})
+}
+
+func TestExactSeenConcurrent(t *testing.T) {
+	// a cache smaller than the number of hosts rebuilds filters all the time
+	seen := newExactSeen(inmem.NewSet(), inmem.NewInMemoryStorage[*boom.ScalableBloomFilter](), 1)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				host := fmt.Sprintf("h%d.com", i%16)
+				url := []byte(fmt.Sprintf("http://%s/%d/%d", host, w, i))
+				if err := seen.add(host, url); err != nil {
+					t.Error(err.Error())
+					return
+				}
+				if ok, err := seen.contains(host, url); err != nil || !ok {
+					t.Errorf("Added url was not found: %s, %v", url, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
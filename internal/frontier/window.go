@@ -0,0 +1,164 @@
+package frontier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CrawlWindow is a time of day, on some days of the week, during which a host
+// may be crawled.
+type CrawlWindow struct {
+	// Days are the days the window starts on. Empty means every day.
+	Days []time.Weekday
+	// Start and End are times of day. A window that ends before it starts
+	// ends on the next day; one that ends when it starts lasts the whole day.
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone of the window.
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseCrawlWindow parses a window from days such as "mon-fri" or "sat,sun",
+// hours such as "22:00-06:00" and an IANA time zone. Empty days mean every
+// day, empty hours the whole day and an empty time zone UTC.
+func ParseCrawlWindow(days string, hours string, timeZone string) (CrawlWindow, error) {
+	var w CrawlWindow
+
+	var err error
+	if w.Days, err = parseDays(days); err != nil {
+		return w, err
+	}
+
+	if hours != "" {
+		start, end, ok := strings.Cut(hours, "-")
+		if !ok {
+			return w, fmt.Errorf("Invalid hours: %q", hours)
+		}
+		if w.Start, err = parseTimeOfDay(start); err != nil {
+			return w, err
+		}
+		if w.End, err = parseTimeOfDay(end); err != nil {
+			return w, err
+		}
+	}
+
+	if w.Location, err = time.LoadLocation(timeZone); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func parseDays(days string) ([]time.Weekday, error) {
+	if days == "" {
+		return nil, nil
+	}
+
+	var parsed []time.Weekday
+	for _, part := range strings.Split(days, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, ok := weekdays[strings.ToLower(from)]
+		if !ok {
+			return nil, fmt.Errorf("Invalid day: %q", from)
+		}
+		if !isRange {
+			parsed = append(parsed, first)
+			continue
+		}
+
+		last, ok := weekdays[strings.ToLower(to)]
+		if !ok {
+			return nil, fmt.Errorf("Invalid day: %q", to)
+		}
+		// ranges may wrap around the week, e.g. fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			parsed = append(parsed, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return parsed, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day: %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w CrawlWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t is inside the window.
+func (w CrawlWindow) contains(t time.Time) bool {
+	if w.Location != nil {
+		t = t.In(w.Location)
+	}
+	day := t.Weekday()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	switch {
+	case w.Start == w.End:
+		return w.startsOn(day)
+	case w.Start < w.End:
+		return w.startsOn(day) && now >= w.Start && now < w.End
+	default:
+		// the window of the day before may still be open
+		return (w.startsOn(day) && now >= w.Start) || (w.startsOn((day+6)%7) && now < w.End)
+	}
+}
+
+// inWindow reports whether the host may be crawled now. Hosts without
+// windows may always be crawled.
+func (f *BfFrontier) inWindow(id string) bool {
+	windows, ok := lookupDomain(f.opts.crawlWindows, id)
+	if !ok {
+		return true
+	}
+
+	now := f.now()
+	for _, w := range windows {
+		if w.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// openWindows wakes the queues whose crawl window opened, every minute.
+func (f *BfFrontier) openWindows() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-f.done:
+			return
+		}
+
+		for f.wakeInactiveQueue() {
+		}
+	}
+}
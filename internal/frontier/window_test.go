@@ -0,0 +1,103 @@
+package frontier
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCrawlWindow(t *testing.T) {
+	nightly, err := ParseCrawlWindow("mon-fri", "22:00-06:00", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	weekend, err := ParseCrawlWindow("sat,sun", "", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		window CrawlWindow
+		at     time.Time
+		want   bool
+	}{
+		{nightly, time.Date(2024, 1, 1, 23, 0, 0, 0, berlin), true},   // monday night
+		{nightly, time.Date(2024, 1, 2, 5, 59, 0, 0, berlin), true},   // into tuesday
+		{nightly, time.Date(2024, 1, 2, 6, 0, 0, 0, berlin), false},   // closed at six
+		{nightly, time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), true}, // 23:00 in Berlin
+		{nightly, time.Date(2024, 1, 1, 21, 30, 0, 0, berlin), false},
+		{nightly, time.Date(2024, 1, 6, 23, 0, 0, 0, berlin), false}, // saturday night
+		{nightly, time.Date(2024, 1, 6, 3, 0, 0, 0, berlin), true},   // friday night
+		{weekend, time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC), true},
+		{weekend, time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		if have := c.window.contains(c.at); have != c.want {
+			t.Errorf("Unexpected window check at %s. Have: %v, want: %v", c.at, have, c.want)
+		}
+	}
+
+	for _, bad := range [][3]string{{"mon-funday", "", ""}, {"", "22:00", ""}, {"", "25:00-06:00", ""}, {"", "", "Mars/Olympus"}} {
+		if _, err := ParseCrawlWindow(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("Invalid window was accepted: %v", bad)
+		}
+	}
+}
+
+func TestCrawlWindowParking(t *testing.T) {
+	office, err := ParseCrawlWindow("", "09:00-17:00", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)}
+	f := newTestFrontier(
+		WithMaxActiveQueues(1),
+		WithClock(clock.Now),
+		WithCrawlWindows(map[string][]CrawlWindow{"partner.com": {office}}),
+	)
+
+	for _, raw := range []string{"http://www.partner.com/1", "http://www.partner.com/2", "http://b.com/1"} {
+		if err := f.Put(mustParse(t, raw), UrlMeta{}); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	// the closed host doesn't take the only slot
+	if f.queueMap["www.partner.com"].IsActive() || !f.queueMap["b.com"].IsActive() {
+		t.Fatalf("Queue was activated outside of its window")
+	}
+	if queue, _ := f.Queue("www.partner.com", 0); queue.State != QueueOutsideWindow {
+		t.Fatalf("Unexpected state of a closed queue: %s", queue.State)
+	}
+
+	u, _, _, err := f.Get(context.Background())
+	if err != nil || toId(u) != "b.com" {
+		t.Fatalf("Unexpected url: %v, %v", u, err)
+	}
+	f.MarkProcessed(u)
+	// b.com runs empty and gives up its slot, which the closed host can't take
+	f.Get(context.Background())
+	if f.wakeInactiveQueue() {
+		t.Fatalf("Queue was woken outside of its window")
+	}
+
+	clock.now = time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	if !f.wakeInactiveQueue() {
+		t.Fatalf("Queue was not woken when its window opened")
+	}
+	u, _, _, err = f.Get(context.Background())
+	if err != nil || toId(u) != "www.partner.com" {
+		t.Fatalf("Unexpected url: %v, %v", u, err)
+	}
+
+	// the window closes while the queue is active
+	clock.now = time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)
+	f.MarkProcessed(u)
+	if _, _, _, err := f.Get(context.Background()); err == nil {
+		t.Fatalf("Url handed out after the window closed")
+	}
+	if f.queueMap["www.partner.com"].IsActive() || f.activeQueues != 0 {
+		t.Fatalf("Closed queue kept its slot")
+	}
+}
@@ -0,0 +1,23 @@
+// Package hostname has the helpers on host names that the filters and the
+// frontier share.
+package hostname
+
+import (
+	"net"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RegisteredDomain returns the eTLD+1 of the host, or the host itself if it
+// has none (e.g. an IP address).
+func RegisteredDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,18 @@
+package hostname
+
+import "testing"
+
+func TestRegisteredDomain(t *testing.T) {
+	cases := map[string]string{
+		"www.example.com":   "example.com",
+		"a.b.example.co.uk": "example.co.uk",
+		"127.0.0.1":         "127.0.0.1",
+		"localhost":         "localhost",
+	}
+
+	for host, want := range cases {
+		if have := RegisteredDomain(host); have != want {
+			t.Errorf("Unexpected registered domain for %s. Have: %s, want: %s", host, have, want)
+		}
+	}
+}
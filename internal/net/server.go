@@ -20,7 +20,11 @@ type Peer struct {
 	addr string
 
 	connPool map[string]*yamux.Session
+	listener net.Listener
+	closed   bool
 	mu       sync.Mutex
+	// inflight counts the requests being handled.
+	inflight sync.WaitGroup
 
 	rqMu            sync.Mutex
 	requestHandlers map[string]RequestHandlerFunc
@@ -249,11 +253,16 @@ func (p *Peer) Listen(ctx context.Context) error {
 	}
 	p.logger.Infof("Listening on %s", p.addr)
 
+	p.mu.Lock()
+	p.listener = l
+	p.mu.Unlock()
+
 	go func() {
-		<-ctx.Done()
-		close(p.quit)
-		p.logger.Infow("Shutting service down...")
-		l.Close()
+		select {
+		case <-ctx.Done():
+			p.Close()
+		case <-p.quit:
+		}
 	}()
 
 	for {
@@ -261,9 +270,10 @@ func (p *Peer) Listen(ctx context.Context) error {
 		if err != nil {
 			select {
 			case <-p.quit:
-				break
+				return nil
 			default:
-				p.logger.Errorln("Error accepting connection: %s", err.Error())
+				p.logger.Errorf("Error accepting connection: %s", err.Error())
+				continue
 			}
 		}
 		go func() {
@@ -283,6 +293,36 @@ func (p *Peer) Listen(ctx context.Context) error {
 	}
 }
 
+// Close stops accepting connections, closes the open sessions and waits for
+// the requests being handled, so that no handler runs after it returned.
+func (p *Peer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.inflight.Wait()
+		return nil
+	}
+	p.closed = true
+	close(p.quit)
+	l := p.listener
+	sessions := make([]*yamux.Session, 0, len(p.connPool))
+	for _, s := range p.connPool {
+		sessions = append(sessions, s)
+	}
+	p.mu.Unlock()
+
+	p.logger.Infow("Shutting service down...")
+	var err error
+	if l != nil {
+		err = l.Close()
+	}
+	for _, s := range sessions {
+		s.Close()
+	}
+	p.inflight.Wait()
+	return err
+}
+
 func (p *Peer) handleSession(session *yamux.Session) error {
 	remote := session.RemoteAddr()
 	for {
@@ -304,8 +344,19 @@ func (p *Peer) handleSession(session *yamux.Session) error {
 			continue
 		}
 
+		// requests are not taken anymore once the peer is closed
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			stream.Close()
+			break
+		}
+		p.inflight.Add(1)
+		p.mu.Unlock()
+
 		go func() {
-			err = p.handleRequest(context.Background(), stream)
+			defer p.inflight.Done()
+			err := p.handleRequest(context.Background(), stream)
 			if err != nil {
 				if err == io.EOF {
 					return
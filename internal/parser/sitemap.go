@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSitemapSize is the uncompressed size limit of the sitemap protocol.
+const maxSitemapSize = 50 * 1024 * 1024
+
+var ErrNotSitemap = errors.New("Not a sitemap")
+
+// SitemapEntry is a url listed by a sitemap, or a sitemap listed by a
+// sitemap index.
+type SitemapEntry struct {
+	Url *url.URL
+	// LastMod is when the page last changed, zero if the sitemap doesn't say.
+	LastMod time.Time
+	// Priority is the priority of the url relative to the other urls of the
+	// site, from 0 to 1. It is 0.5 if the sitemap doesn't say.
+	Priority float64
+	// Sitemap is set on the sitemaps of a sitemap index.
+	Sitemap bool
+}
+
+type xmlSitemap struct {
+	XMLName  xml.Name
+	Urls     []xmlSitemapUrl `xml:"url"`
+	Sitemaps []xmlSitemapUrl `xml:"sitemap"`
+}
+
+type xmlSitemapUrl struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod"`
+	Priority string `xml:"priority"`
+}
+
+// ParseSitemap parses an XML sitemap or sitemap index, gzipped or not.
+// Entries that don't parse are skipped.
+func ParseSitemap(base *url.URL, input []byte) ([]SitemapEntry, error) {
+	var reader io.Reader = bytes.NewReader(input)
+	if bytes.HasPrefix(input, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	var doc xmlSitemap
+	if err := xml.NewDecoder(io.LimitReader(reader, maxSitemapSize)).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var isIndex bool
+	switch doc.XMLName.Local {
+	case "urlset":
+	case "sitemapindex":
+		isIndex = true
+	default:
+		return nil, ErrNotSitemap
+	}
+
+	listed := doc.Urls
+	if isIndex {
+		listed = doc.Sitemaps
+	}
+
+	entries := make([]SitemapEntry, 0, len(listed))
+	for _, l := range listed {
+		u, err := base.Parse(strings.TrimSpace(l.Loc))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		entries = append(entries, SitemapEntry{
+			Url:      u,
+			LastMod:  parseLastMod(l.LastMod),
+			Priority: parsePriority(l.Priority),
+			Sitemap:  isIndex,
+		})
+	}
+	return entries, nil
+}
+
+// defaultPriority is the priority of urls listed without one.
+const defaultPriority = 0.5
+
+func parsePriority(s string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || p < 0 || p > 1 {
+		return defaultPriority
+	}
+	return p
+}
+
+// lastModLayouts are the W3C Datetime forms a lastmod may take.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSitemap(t *testing.T) {
+	base, _ := url.Parse("http://a.com/sitemap.xml")
+
+	urlset := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://a.com/1</loc><lastmod>2024-01-02</lastmod><priority>0.8</priority></url>
+  <url><loc>http://a.com/2</loc><lastmod>2024-01-02T10:30:00+01:00</lastmod></url>
+  <url><loc> http://a.com/3 </loc><priority>7</priority></url>
+  <url><loc>::</loc></url>
+</urlset>`)
+
+	entries, err := ParseSitemap(base, urlset)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !entries[0].LastMod.Equal(want) {
+		t.Fatalf("Unexpected lastmod. Have: %s, want: %s", entries[0].LastMod, want)
+	}
+	if want := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC); !entries[1].LastMod.Equal(want) {
+		t.Fatalf("Unexpected lastmod. Have: %s, want: %s", entries[1].LastMod, want)
+	}
+	if entries[2].Url.String() != "http://a.com/3" || !entries[2].LastMod.IsZero() || entries[2].Sitemap {
+		t.Fatalf("Unexpected entry: %+v", entries[2])
+	}
+	for i, want := range []float64{0.8, 0.5, 0.5} {
+		if entries[i].Priority != want {
+			t.Errorf("Unexpected priority of %s. Have: %v, want: %v", entries[i].Url, entries[i].Priority, want)
+		}
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<sitemapindex><sitemap><loc>/sitemap-2.xml</loc></sitemap></sitemapindex>`))
+	zw.Close()
+
+	entries, err = ParseSitemap(base, gz.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || !entries[0].Sitemap || entries[0].Url.String() != "http://a.com/sitemap-2.xml" {
+		t.Fatalf("Unexpected index entries: %+v", entries)
+	}
+
+	if _, err := ParseSitemap(base, []byte(`<html><body></body></html>`)); err != ErrNotSitemap {
+		t.Fatalf("Page was parsed as a sitemap: %v", err)
+	}
+}
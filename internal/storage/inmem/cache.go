@@ -32,7 +32,12 @@ func (c *LruCache[V]) Get(key string) (V, error) {
 }
 
 func (c *LruCache[V]) Put(key string, val V) error {
-	if len(c.cache) >= int(c.windowSize) {
+	if _, ok := c.cache[key]; ok {
+		c.cache[key] = val
+		return nil
+	}
+
+	for len(c.cache) >= int(c.windowSize) {
 		if err := c.offload(); err != nil {
 			return err
 		}
@@ -43,17 +48,30 @@ func (c *LruCache[V]) Put(key string, val V) error {
 	return nil
 }
 
+// offload evicts the oldest entry. Keys that were deleted meanwhile are
+// skipped.
 func (c *LruCache[V]) offload() error {
-	oldKey, err := c.queue.Pop()
-	if err != nil {
-		return err
+	for {
+		oldKey, err := c.queue.Pop()
+		if err != nil {
+			return err
+		}
+
+		if oldVal, ok := c.cache[oldKey]; ok {
+			c.onEvict(oldKey, oldVal)
+			delete(c.cache, oldKey)
+			return nil
+		}
 	}
+}
 
-	if oldVal, ok := c.cache[oldKey]; ok {
-		c.onEvict(oldKey, oldVal)
-		delete(c.cache, oldKey)
+// Range calls fn for every cached entry until it returns false.
+func (c *LruCache[V]) Range(fn func(k string, v V) bool) {
+	for k, v := range c.cache {
+		if !fn(k, v) {
+			return
+		}
 	}
-	return nil
 }
 
 func (c *LruCache[V]) Delete(key string) error {
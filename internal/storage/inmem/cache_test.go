@@ -43,6 +43,30 @@ func TestPutGet(t *testing.T) {
 	}
 }
 
+func TestPutExisting(t *testing.T) {
+	cache := setup()
+	var evicted []string
+	cache.SetOnEvict(func(k, v string) {
+		evicted = append(evicted, k)
+	})
+
+	cache.Put("1", "1")
+	cache.Put("2", "2")
+	cache.Put("3", "3")
+	cache.Put("3", "4")
+	if len(evicted) != 0 {
+		t.Fatalf("Update of a cached key evicted: %v", evicted)
+	}
+
+	// a deleted key doesn't count against the size
+	cache.Delete("1")
+	cache.Put("4", "4")
+	cache.Put("5", "5")
+	if len(evicted) != 1 || evicted[0] != "2" {
+		t.Fatalf("Unexpected evictions. Have: %v, want: [2]", evicted)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	cache := setup()
 	cache.Put("1", "1")
@@ -54,3 +78,25 @@ func TestDelete(t *testing.T) {
 		t.Fatalf("Value was not deleted")
 	}
 }
+
+func TestSlidingFlush(t *testing.T) {
+	backing := NewInMemoryStorage[string]()
+	sliding := NewSlidingStorage[string](backing, 3)
+
+	sliding.Put("1", "1")
+	sliding.Put("2", "2")
+
+	if _, err := backing.Get("1"); err != storage.NoSuchKeyError {
+		t.Fatalf("Entry written through before eviction or flush")
+	}
+
+	if err := sliding.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, k := range []string{"1", "2"} {
+		if v, err := backing.Get(k); err != nil || v != k {
+			t.Fatalf("Entry %s was not flushed", k)
+		}
+	}
+}
@@ -27,6 +27,24 @@ func (p *PriorityQueue[T]) Pop() (T, int, bool) {
 	return item.value, item.priority, true
 }
 
+// Peek returns the next item without removing it.
+func (p *PriorityQueue[T]) Peek() (T, int, bool) {
+	if p.pq.Len() == 0 {
+		return *new(T), 0, false
+	}
+	item := (*p.pq)[0]
+	return item.value, item.priority, true
+}
+
+// Values returns all items, in no particular order.
+func (p *PriorityQueue[T]) Values() []T {
+	values := make([]T, len(*p.pq))
+	for i, item := range *p.pq {
+		values[i] = item.value
+	}
+	return values
+}
+
 func (p *PriorityQueue[T]) Length() int {
 	return p.pq.Len()
 }
@@ -1,37 +1,63 @@
 package inmem
 
-import "github.com/xunterr/aracno/internal/storage"
+import (
+	"sync"
 
-type InMemoryQueue[T any] []T
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+type InMemoryQueue[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
 
 func NewQueue[T any]() *InMemoryQueue[T] {
 	return &InMemoryQueue[T]{}
 }
 
 func (q *InMemoryQueue[T]) Push(el T) error {
-	*q = append(*q, el)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = append(q.items, el)
 	return nil
 }
 
 func (q *InMemoryQueue[T]) Peek() (T, error) {
-	if len(*q) == 0 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
 		return *new(T), storage.NoNextItem
 	}
-	return (*q)[0], nil
+	return q.items[0], nil
+}
+
+func (q *InMemoryQueue[T]) PeekN(n int) ([]T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n = min(n, len(q.items))
+	return append([]T{}, q.items[:n]...), nil
 }
 
 func (q *InMemoryQueue[T]) Pop() (T, error) {
-	if len(*q) == 0 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
 		return *new(T), storage.NoNextItem
 	}
 
-	val := (*q)[0]
+	val := q.items[0]
 
-	*q = (*q)[1:len(*q)]
+	q.items = q.items[1:]
 
 	return val, nil
 }
 
 func (q *InMemoryQueue[T]) Len() int {
-	return len(*q)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
 }
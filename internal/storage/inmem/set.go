@@ -0,0 +1,60 @@
+package inmem
+
+import (
+	"strings"
+	"sync"
+)
+
+type Set struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+func NewSet() *Set {
+	return &Set{
+		keys: make(map[string]struct{}),
+	}
+}
+
+func (s *Set) Has(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+func (s *Set) Add(key string) error {
+	s.mu.Lock()
+	s.keys[key] = struct{}{}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Set) Scan(prefix string, fn func(key string) error) error {
+	s.mu.RLock()
+	var keys []string
+	for key := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Set) DeletePrefix(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.keys, key)
+		}
+	}
+	return nil
+}
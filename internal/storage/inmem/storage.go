@@ -29,6 +29,17 @@ func (s *InMemoryStorage[V]) Get(key string) (V, error) {
 	return val, nil
 }
 
+func (s *InMemoryStorage[V]) GetAll() (map[string]V, error) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	all := make(map[string]V, len(s.store))
+	for k, v := range s.store {
+		all[k] = v
+	}
+	return all, nil
+}
+
 func (s *InMemoryStorage[V]) Put(key string, value V) error {
 	s.storeMu.Lock()
 	defer s.storeMu.Unlock()
@@ -36,6 +47,15 @@ func (s *InMemoryStorage[V]) Put(key string, value V) error {
 	return nil
 }
 
+func (s *InMemoryStorage[V]) PutAll(values map[string]V) error {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	for k, v := range values {
+		s.store[k] = v
+	}
+	return nil
+}
+
 func (s *InMemoryStorage[V]) Delete(key string) error {
 	s.storeMu.Lock()
 	delete(s.store, key)
@@ -74,6 +94,16 @@ func (s *SlidingStorage[V]) Put(key string, val V) error {
 	return s.cache.Put(key, val)
 }
 
+// Flush writes all cached entries to the underlying storage. They stay cached.
+func (s *SlidingStorage[V]) Flush() error {
+	var err error
+	s.cache.Range(func(k string, v V) bool {
+		err = s.storage.Put(k, v)
+		return err == nil
+	})
+	return err
+}
+
 func (s *SlidingStorage[V]) Delete(key string) error {
 	s.cache.Delete(key)
 	return s.storage.Delete(key)
@@ -12,3 +12,9 @@ type Queue[T any] interface {
 	Pop() (T, error)
 	Len() int
 }
+
+// Peeker is implemented by queues that can return their first n entries
+// without removing them.
+type Peeker[T any] interface {
+	PeekN(n int) ([]T, error)
+}
@@ -0,0 +1,160 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/linxGnu/grocksdb"
+	"github.com/xunterr/aracno/internal/storage"
+)
+
+// RocksdbPriorityQueue pops entries with the highest score first and entries
+// with equal scores in insertion order. The order is kept by the keys, which
+// are queueId, a zero byte, the inverted score and a sequence number.
+type RocksdbPriorityQueue[V any] struct {
+	qMu     sync.Mutex
+	storage *RocksdbStorage[V]
+	score   func(V) uint32
+	queueId []byte
+	seq     uint64
+	len     int
+}
+
+func NewRocksdbPriorityQueue[V any](storage *RocksdbStorage[V], queueId []byte, score func(V) uint32) *RocksdbPriorityQueue[V] {
+	pq := &RocksdbPriorityQueue[V]{
+		storage: storage,
+		score:   score,
+		queueId: append(append([]byte{}, queueId...), 0),
+	}
+
+	pq.initQueue()
+	return pq
+}
+
+func (r *RocksdbPriorityQueue[V]) getKey(score uint32, seq uint64) []byte {
+	buff := append([]byte{}, r.queueId...)
+	buff = binary.BigEndian.AppendUint32(buff, math.MaxUint32-score)
+	buff = binary.BigEndian.AppendUint64(buff, seq)
+	return buff
+}
+
+func (r *RocksdbPriorityQueue[V]) upperBound() []byte {
+	bound := append([]byte{}, r.queueId...)
+	bound[len(bound)-1] = 1
+	return bound
+}
+
+func (r *RocksdbPriorityQueue[V]) iter() (*grocksdb.Iterator, func()) {
+	ro := grocksdb.NewDefaultReadOptions()
+	ro.SetIterateUpperBound(r.upperBound())
+	it := r.storage.getIter(ro)
+	it.Seek(r.queueId)
+	return it, func() {
+		it.Close()
+		ro.Destroy()
+	}
+}
+
+func (r *RocksdbPriorityQueue[V]) initQueue() {
+	it, done := r.iter()
+	defer done()
+
+	for ; it.Valid(); it.Next() {
+		key := it.Key()
+		data := key.Data()
+		if seq := binary.BigEndian.Uint64(data[len(data)-8:]); seq >= r.seq {
+			r.seq = seq + 1
+		}
+		r.len++
+		key.Free()
+	}
+}
+
+func (r *RocksdbPriorityQueue[V]) Push(entry V) error {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
+	key := r.getKey(r.score(entry), r.seq)
+	if err := r.storage.Put(string(key), entry); err != nil {
+		return err
+	}
+	r.seq++
+	r.len++
+	return nil
+}
+
+// first returns the key and value of the entry with the highest score.
+// Must be called with r.qMu held.
+func (r *RocksdbPriorityQueue[V]) first() ([]byte, V, error) {
+	it, done := r.iter()
+	defer done()
+
+	if !it.Valid() {
+		if err := it.Err(); err != nil {
+			return nil, *new(V), err
+		}
+		return nil, *new(V), storage.NoNextItem
+	}
+
+	key := it.Key()
+	value := it.Value()
+	defer key.Free()
+	defer value.Free()
+
+	decoded, err := r.storage.decode(value.Data())
+	if err != nil {
+		return nil, *new(V), err
+	}
+	return append([]byte{}, key.Data()...), decoded, nil
+}
+
+func (r *RocksdbPriorityQueue[V]) Pop() (V, error) {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
+	key, value, err := r.first()
+	if err != nil {
+		return *new(V), err
+	}
+
+	if err := r.storage.Delete(string(key)); err != nil {
+		return *new(V), err
+	}
+	r.len--
+	return value, nil
+}
+
+func (r *RocksdbPriorityQueue[V]) Peek() (V, error) {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
+	_, value, err := r.first()
+	return value, err
+}
+
+func (r *RocksdbPriorityQueue[V]) PeekN(n int) ([]V, error) {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
+	it, done := r.iter()
+	defer done()
+
+	var values []V
+	for ; it.Valid() && len(values) < n; it.Next() {
+		value := it.Value()
+		decoded, err := r.storage.decode(value.Data())
+		value.Free()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, decoded)
+	}
+	return values, it.Err()
+}
+
+func (r *RocksdbPriorityQueue[V]) Len() int {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+	return r.len
+}
@@ -0,0 +1,48 @@
+package rocksdb
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	db, err := openTest()
+	defer db.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	score := func(v string) uint32 {
+		s, _ := strconv.Atoi(v[:1])
+		return uint32(s)
+	}
+
+	st := NewRocksdbStorage[string](db)
+	queue := NewRocksdbPriorityQueue(st, []byte("mypq"), score)
+	for _, v := range []string{"1a", "5a", "3a", "5b", "1b"} {
+		if err := queue.Push(v); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// a queue whose id is a prefix of another one must not see its entries
+	other := NewRocksdbPriorityQueue(st, []byte("mypq2"), score)
+	if err := other.Push("9a"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reopened := NewRocksdbPriorityQueue(st, []byte("mypq"), score)
+	if reopened.Len() != 5 {
+		t.Fatalf("Unexpected length after reopening. Have: %d, want: 5", reopened.Len())
+	}
+
+	for _, want := range []string{"5a", "5b", "3a", "1a", "1b"} {
+		have, err := reopened.Pop()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if have != want {
+			t.Fatalf("Wrong order. Have: %s, want: %s", have, want)
+		}
+	}
+}
@@ -79,6 +79,9 @@ func (r *RocksdbQueue[V]) Push(entry V) error {
 }
 
 func (r *RocksdbQueue[V]) Pop() (V, error) {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
 	id := r.getKey(r.head)
 	value, err := r.storage.Get(string(id))
 	if err != nil {
@@ -112,6 +115,24 @@ func (r *RocksdbQueue[V]) Peek() (V, error) {
 	return value, nil
 }
 
+func (r *RocksdbQueue[V]) PeekN(n int) ([]V, error) {
+	r.qMu.Lock()
+	defer r.qMu.Unlock()
+
+	var values []V
+	for seq := r.head; seq < r.tail && len(values) < n; seq++ {
+		value, err := r.storage.Get(string(r.getKey(seq)))
+		if err != nil {
+			if err == storage.NoSuchKeyError {
+				continue
+			}
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 func (r *RocksdbQueue[V]) Len() int {
 	r.qMu.Lock()
 	defer r.qMu.Unlock()
@@ -0,0 +1,76 @@
+package rocksdb
+
+import (
+	"github.com/linxGnu/grocksdb"
+)
+
+// RocksdbSet keeps the keys of a set with empty values.
+type RocksdbSet struct {
+	db       *grocksdb.DB
+	cfHandle *grocksdb.ColumnFamilyHandle
+
+	ro *grocksdb.ReadOptions
+	wo *grocksdb.WriteOptions
+}
+
+func NewRocksdbSet(db *grocksdb.DB, opts ...RocksdbStorageOption) *RocksdbSet {
+	defaultOpts := defaultOptions(db)
+	for _, opt := range opts {
+		opt(defaultOpts)
+	}
+
+	return &RocksdbSet{
+		db:       db,
+		cfHandle: defaultOpts.cfHandle,
+		ro:       grocksdb.NewDefaultReadOptions(),
+		wo:       grocksdb.NewDefaultWriteOptions(),
+	}
+}
+
+func (s *RocksdbSet) Has(key string) (bool, error) {
+	value, err := s.db.GetCF(s.ro, s.cfHandle, []byte(key))
+	if err != nil {
+		return false, err
+	}
+	defer value.Free()
+	return value.Exists(), nil
+}
+
+func (s *RocksdbSet) Add(key string) error {
+	return s.db.PutCF(s.wo, s.cfHandle, []byte(key), nil)
+}
+
+func (s *RocksdbSet) Scan(prefix string, fn func(key string) error) error {
+	it := s.db.NewIteratorCF(s.ro, s.cfHandle)
+	defer it.Close()
+
+	p := []byte(prefix)
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		key := it.Key()
+		err := fn(string(key.Data()))
+		key.Free()
+		if err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+func (s *RocksdbSet) DeletePrefix(prefix string) error {
+	wb := grocksdb.NewWriteBatch()
+	defer wb.Destroy()
+
+	err := s.Scan(prefix, func(key string) error {
+		wb.DeleteCF(s.cfHandle, []byte(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.Write(s.wo, wb)
+}
+
+func (s *RocksdbSet) Close() {
+	s.ro.Destroy()
+	s.wo.Destroy()
+}
@@ -0,0 +1,38 @@
+package rocksdb
+
+import (
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	db, err := openTest()
+	defer db.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	set := NewRocksdbSet(db)
+	for _, key := range []string{"a\x001", "a\x002", "ab\x001"} {
+		if err := set.Add(key); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	var scanned int
+	set.Scan("a\x00", func(key string) error {
+		scanned++
+		return nil
+	})
+	if scanned != 2 {
+		t.Fatalf("Unexpected number of scanned keys. Have: %d, want: 2", scanned)
+	}
+
+	if err := set.DeletePrefix("a\x00"); err != nil {
+		t.Fatal(err.Error())
+	}
+	for key, want := range map[string]bool{"a\x001": false, "ab\x001": true} {
+		if have, _ := set.Has(key); have != want {
+			t.Fatalf("Unexpected membership of %q. Have: %t, want: %t", key, have, want)
+		}
+	}
+}
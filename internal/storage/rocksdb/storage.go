@@ -120,6 +120,21 @@ func (s *RocksdbStorage[V]) Put(key string, value V) error {
 	return err
 }
 
+// PutAll writes the values in a single WriteBatch.
+func (s *RocksdbStorage[V]) PutAll(values map[string]V) error {
+	wb := grocksdb.NewWriteBatch()
+	defer wb.Destroy()
+
+	for key, value := range values {
+		bytes, err := s.encode(value)
+		if err != nil {
+			return err
+		}
+		wb.PutCF(s.cfHandle, []byte(key), bytes)
+	}
+	return s.db.Write(s.wo, wb)
+}
+
 func (s *RocksdbStorage[V]) Delete(key string) error {
 	return s.db.DeleteCF(s.wo, s.cfHandle, []byte(key))
 }
@@ -9,3 +9,38 @@ type Storage[V any] interface {
 	Put(string, V) error
 	Delete(string) error
 }
+
+// Flusher is implemented by storages that buffer writes.
+type Flusher interface {
+	Flush() error
+}
+
+// Set is a set of keys that can be listed and removed by prefix.
+type Set interface {
+	Has(key string) (bool, error)
+	Add(key string) error
+	// Scan calls fn with every key that starts with prefix, until fn returns
+	// an error.
+	Scan(prefix string, fn func(key string) error) error
+	DeletePrefix(prefix string) error
+}
+
+// Batcher is implemented by storages that can write several values at once.
+type Batcher[V any] interface {
+	PutAll(map[string]V) error
+}
+
+// PutAll writes the values in one batch if the storage supports it, and one
+// by one otherwise.
+func PutAll[V any](s Storage[V], values map[string]V) error {
+	if batcher, ok := s.(Batcher[V]); ok {
+		return batcher.PutAll(values)
+	}
+
+	for k, v := range values {
+		if err := s.Put(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package warc
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/slyrz/warc"
@@ -38,3 +41,38 @@ func TestCapture(t *testing.T) {
 		}
 	}
 }
+
+func TestWriterFlush(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWarcWriter(dir)
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("Flush without records created a file")
+	}
+
+	record, err := ResourceRecord([]byte("hello"), "http://a.com/", "text/plain")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := w.Write(record); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Unexpected number of files: %d", len(files))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(data), "hello") {
+		t.Fatalf("Record was not flushed")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/slyrz/warc"
@@ -21,6 +22,8 @@ type WarcWriter struct {
 	maxFileSize     int64
 	maxBuffSize     int64
 	bytesSinceFlush int64
+	pending         bool
+	zips            sync.WaitGroup
 }
 
 func NewWarcWriter(path string) *WarcWriter {
@@ -42,6 +45,7 @@ func (w *WarcWriter) Write(record *warc.Record) error {
 	if err != nil {
 		return err
 	}
+	w.pending = true
 
 	if w.buff.Len() >= int(w.maxBuffSize) {
 		return w.dumpToFile()
@@ -62,12 +66,15 @@ func (w *WarcWriter) dumpToFile() error {
 	}
 
 	w.bytesSinceFlush += n
+	w.pending = false
 	buf.Flush()
 
 	if w.bytesSinceFlush >= w.maxFileSize {
 		file.Seek(0, 0)
 
+		w.zips.Add(1)
 		go func(filename string) {
+			defer w.zips.Done()
 			defer file.Close()
 			if err := w.zip(filename, file); err != nil { //todo: improve error handling
 				return
@@ -86,6 +93,17 @@ func (w *WarcWriter) dumpToFile() error {
 	return nil
 }
 
+// Flush writes the buffered records to the current file and waits for the
+// files being compressed.
+func (w *WarcWriter) Flush() error {
+	var err error
+	if w.pending {
+		err = w.dumpToFile()
+	}
+	w.zips.Wait()
+	return err
+}
+
 func (w *WarcWriter) getFile() (*os.File, error) {
 	var file *os.File
 	if w.currFile == "" {
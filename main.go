@@ -4,13 +4,20 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/linxGnu/grocksdb"
@@ -46,25 +53,121 @@ var (
 type persistentQp struct {
 	db              *grocksdb.DB
 	queueStorage    *rocksdb.RocksdbStorage[frontier.Url]
+	priorityStorage *rocksdb.RocksdbStorage[frontier.Url]
 	metadataStorage *rocksdb.RocksdbStorage[string]
+	priority        bool
 }
 
-func newPersistentQp(path string) (*persistentQp, error) {
-	db, cfs, err := createDefaultDBWithCF(path, []string{"metadata", "data"})
+// newPersistentQp opens the queue database. FIFO and priority ordered queues
+// are kept in separate column families; urls queued in the other order by an
+// earlier run are moved over.
+func newPersistentQp(path string, priority bool) (*persistentQp, error) {
+	db, cfs, err := createDefaultDBWithCF(path, []string{"metadata", "data", "priority"})
 	if err != nil {
 		return nil, err
 	}
 
 	metadataCF := cfs[0]
 	dataCF := cfs[1]
+	priorityCF := cfs[2]
 
 	metadataStorage := rocksdb.NewRocksdbStorage[string](db, rocksdb.WithCF(metadataCF))
 	queueStorage := rocksdb.NewRocksdbStorage[frontier.Url](db, rocksdb.WithCF(dataCF))
-	return &persistentQp{
+	priorityStorage := rocksdb.NewRocksdbStorage[frontier.Url](db, rocksdb.WithCF(priorityCF))
+	qp := &persistentQp{
 		db:              db,
 		queueStorage:    queueStorage,
+		priorityStorage: priorityStorage,
 		metadataStorage: metadataStorage,
-	}, nil
+		priority:        priority,
+	}
+	if err := qp.migrate(); err != nil {
+		return nil, fmt.Errorf("Failed to migrate queues to the %s order: %w", qp.order(), err)
+	}
+	return qp, nil
+}
+
+func (qp *persistentQp) order() string {
+	if qp.priority {
+		return "priority"
+	}
+	return "fifo"
+}
+
+func (qp *persistentQp) open(id string) storage.Queue[frontier.Url] {
+	return qp.openOrdered(id, qp.priority)
+}
+
+func (qp *persistentQp) openOrdered(id string, priority bool) storage.Queue[frontier.Url] {
+	if priority {
+		return rocksdb.NewRocksdbPriorityQueue(qp.priorityStorage, []byte(id), frontier.Url.Score)
+	}
+	return rocksdb.NewRocksdbQueue(qp.queueStorage, []byte(id))
+}
+
+// migrate moves the urls left in the column family of the other queue order,
+// after politeness.queue_order was changed, into the queues of the current
+// order. A url is pushed before it is popped, so a crash can only duplicate
+// it.
+func (qp *persistentQp) migrate() error {
+	metadata, err := qp.metadataStorage.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for id := range metadata {
+		from := qp.openOrdered(id, !qp.priority)
+		if from.Len() == 0 {
+			continue
+		}
+
+		to := qp.open(id)
+		for {
+			u, err := from.Peek()
+			if err == storage.NoNextItem {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := to.Push(u); err != nil {
+				return err
+			}
+			if _, err := from.Pop(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type openDb struct {
+	db  *grocksdb.DB
+	cfs grocksdb.ColumnFamilyHandles
+}
+
+// openDbs are the databases to close on shutdown.
+var (
+	openDbs   []openDb
+	openDbsMu sync.Mutex
+)
+
+func trackDb(db *grocksdb.DB, cfs grocksdb.ColumnFamilyHandles) {
+	openDbsMu.Lock()
+	openDbs = append(openDbs, openDb{db: db, cfs: cfs})
+	openDbsMu.Unlock()
+}
+
+func closeDbs() {
+	openDbsMu.Lock()
+	defer openDbsMu.Unlock()
+	for _, o := range openDbs {
+		for _, cf := range o.cfs {
+			cf.Destroy()
+		}
+		o.db.Close()
+	}
+	openDbs = nil
 }
 
 func createDefaultDBWithCF(path string, cfs []string) (*grocksdb.DB, grocksdb.ColumnFamilyHandles, error) {
@@ -73,15 +176,62 @@ func createDefaultDBWithCF(path string, cfs []string) (*grocksdb.DB, grocksdb.Co
 	for _ = range cfs {
 		opts = append(opts, grocksdb.NewDefaultOptions())
 	}
-	return grocksdb.OpenDbColumnFamilies(getDbOpts(), path, cfs, opts)
+	db, handles, err := grocksdb.OpenDbColumnFamilies(getDbOpts(), path, cfs, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	trackDb(db, handles)
+	return db, handles, nil
 }
 
 func (qp *persistentQp) Get(id string) (storage.Queue[frontier.Url], error) {
-	err := qp.metadataStorage.Put(id, "")
+	_, err := qp.metadataStorage.Get(id)
+	if err == storage.NoSuchKeyError {
+		err = qp.metadataStorage.Put(id, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return qp.open(id), nil
+}
+
+// queueStates keeps the scheduling state of each queue as JSON in its
+// metadata entry. An empty entry is a queue without a saved state.
+type queueStates struct {
+	metadata *rocksdb.RocksdbStorage[string]
+}
+
+func (s queueStates) GetAll() (map[string]frontier.SchedulingState, error) {
+	metadata, err := s.metadata.GetAll()
 	if err != nil {
 		return nil, err
 	}
-	return rocksdb.NewRocksdbQueue(qp.queueStorage, []byte(id)), nil
+
+	states := make(map[string]frontier.SchedulingState, len(metadata))
+	for id, raw := range metadata {
+		if raw == "" {
+			continue
+		}
+
+		var state frontier.SchedulingState
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			return nil, fmt.Errorf("Invalid state of queue %s: %w", id, err)
+		}
+		states[id] = state
+	}
+	return states, nil
+}
+
+func (s queueStates) PutAll(states map[string]frontier.SchedulingState) error {
+	metadata := make(map[string]string, len(states))
+	for id, state := range states {
+		raw, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		metadata[id] = string(raw)
+	}
+	return s.metadata.PutAll(metadata)
 }
 
 func (qp *persistentQp) GetAll() (map[string]storage.Queue[frontier.Url], error) {
@@ -92,12 +242,31 @@ func (qp *persistentQp) GetAll() (map[string]storage.Queue[frontier.Url], error)
 	}
 
 	for k, _ := range metadata {
-		queue := rocksdb.NewRocksdbQueue(qp.queueStorage, []byte(k))
-		queueMap[k] = queue
+		queueMap[k] = qp.open(k)
 	}
 	return queueMap, nil
 }
 
+// newCrawlLog returns a logger that appends one JSON line per processed url
+// to the file at path.
+func newCrawlLog(path string) (*zap.Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := zap.NewProductionEncoderConfig()
+	conf.EncodeTime = zapcore.ISO8601TimeEncoder
+	conf.MessageKey = "outcome"
+	conf.LevelKey = zapcore.OmitKey
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(conf), zapcore.AddSync(file), zapcore.InfoLevel)
+	return zap.New(core), nil
+}
+
 func initLogger(level zapcore.Level) *zap.Logger {
 	conf := zap.NewProductionEncoderConfig()
 	conf.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -107,29 +276,61 @@ func initLogger(level zapcore.Level) *zap.Logger {
 	return l
 }
 
-func readSeed(path string) ([]*url.URL, error) {
+type seed struct {
+	url  *url.URL
+	meta frontier.UrlMeta
+}
+
+// readSeed reads one seed per line: a url optionally followed by
+// space-separated options, e.g. "https://example.com max_depth=3".
+func readSeed(path string) ([]seed, error) {
 	dat, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 
 	scanner := bufio.NewScanner(dat)
-	urls := []*url.URL{}
+	seeds := []seed{}
 
 	for scanner.Scan() {
-		url, err := url.Parse(scanner.Text())
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		url, err := url.Parse(fields[0])
 		if err != nil {
-			return urls, err
+			return seeds, err
 		}
 
-		urls = append(urls, url)
+		s := seed{url: url}
+		for _, opt := range fields[1:] {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "priority":
+				priority, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return seeds, fmt.Errorf("Invalid priority for seed %s: %s", fields[0], value)
+				}
+				s.meta.Priority = uint32(priority)
+			case "max_depth":
+				maxDepth, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return seeds, fmt.Errorf("Invalid max_depth for seed %s: %s", fields[0], value)
+				}
+				s.meta.MaxDepth = uint32(maxDepth)
+			default:
+				return seeds, fmt.Errorf("Unknown seed option: %s", opt)
+			}
+		}
+
+		seeds = append(seeds, s)
 	}
-	return urls, nil
+	return seeds, nil
 }
 
 func main() {
 	var wg sync.WaitGroup
-	wg.Add(1)
 
 	defaultLogger := initLogger(zapcore.InfoLevel)
 	defer defaultLogger.Sync()
@@ -140,33 +341,144 @@ func main() {
 		logger.Fatalln(err)
 	}
 
+	scopeFilter, err := makeScopeFilter(conf.Scope)
+	if err != nil {
+		logger.Fatalf("Invalid scope configuration: %s", err.Error())
+	}
+
+	seedMode, err := filter.ParseSeedScopeMode(conf.Scope.SeedMode)
+	if err != nil {
+		logger.Fatalf("Invalid scope configuration: %s", err.Error())
+	}
+
 	http.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: ":8080"}
 	go func() {
-		logger.Fatalln(http.ListenAndServe(":8080", nil))
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Fatalln(err)
+		}
 	}()
 
-	var frontier frontier.Frontier
-	if conf.Distributed.Addr != "" {
-		frontier = makeDistributedFrontier(logger, makeFrontier(conf.Politeness), conf.Distributed)
-	} else {
-		frontier = makeFrontier(conf.Politeness)
+	seeds, err := readSeed(conf.Seed)
+	if err != nil {
+		logger.Errorf("Can't parse seed: %s", err.Error())
+	}
+
+	var bfFrontier *frontier.BfFrontier
+	// lists are only watched once the frontier exists, their change
+	// handler purges it
+	var watched []*filter.WatchedList
+
+	enqueueFilter := filter.NewFilterChain()
+	fc := filter.NewFilterChain()
+
+	if len(conf.Lists.Block) > 0 {
+		var opts []filter.ListOption
+		if conf.Lists.PurgeBlocked {
+			opts = append(opts, filter.WithListChangeHandler(func(prev *filter.List, next *filter.List) {
+				n := bfFrontier.PurgeUrls(func(host string) bool {
+					return (next.MatchesHost(host) && !prev.MatchesHost(host)) || next.HasPrefixes(host)
+				}, next.Matches)
+				logger.Infof("Purged %d urls of newly blocked hosts and prefixes", n)
+			}))
+		}
+
+		blocklist, err := filter.NewWatchedList(defaultLogger, "block", conf.Lists.Block, opts...)
+		if err != nil {
+			logger.Fatalf("Can't load blocklist: %s", err.Error())
+		}
+		watched = append(watched, blocklist)
+
+		enqueueFilter.Append("blocklist", filter.NewBlocklistFilter(blocklist))
+		fc.Append("blocklist", filter.NewBlocklistFilter(blocklist))
+	}
+
+	if len(conf.Lists.Allow) > 0 {
+		allowlist, err := filter.NewWatchedList(defaultLogger, "allow", conf.Lists.Allow)
+		if err != nil {
+			logger.Fatalf("Can't load allowlist: %s", err.Error())
+		}
+		watched = append(watched, allowlist)
+
+		enqueueFilter.Append("allowlist", filter.NewAllowlistFilter(allowlist))
+		fc.Append("allowlist", filter.NewAllowlistFilter(allowlist))
+	}
+
+	enqueueFilter.Append("scope", scopeFilter)
+	enqueueFilter.Append("extension", filter.NewExtensionFilter(conf.Mime.SkipExtensions))
+	if seedMode != filter.SeedScopeNone {
+		seedUrls := make([]*url.URL, len(seeds))
+		for i, s := range seeds {
+			seedUrls[i] = s.url
+		}
+		enqueueFilter.Append("seed_scope", filter.NewSeedScopeFilter(seedMode, seedUrls))
+	}
+
+	var traps *filter.TrapDetector
+	if conf.Traps.Enabled {
+		traps = makeTrapDetector(defaultLogger, conf.Traps)
+		enqueueFilter.Append("trap", traps.Filter())
+	}
+
+	bfFrontier, err = makeFrontier(conf, enqueueFilter, traps)
+	if err != nil {
+		logger.Fatalln(err)
 	}
 
-	urls, err := readSeed(conf.Seed)
+	recovered, err := bfFrontier.RecoverLeases()
 	if err != nil {
-		logger.Errorf("Can't parse URL: %s", err.Error())
+		logger.Errorf("Failed to recover urls in flight: %s", err.Error())
+	} else if recovered > 0 {
+		logger.Infof("Queued %d urls that were in flight when the crawler stopped", recovered)
+	}
+
+	if conf.Import != "" || conf.Export != "" {
+		if err := transfer(logger, bfFrontier, conf.Import, conf.Export); err != nil {
+			logger.Errorln(err)
+		}
+		if err := bfFrontier.Close(); err != nil {
+			logger.Errorf("Failed to close the frontier: %s", err.Error())
+		}
+		closeDbs()
+		return
+	}
+
+	for _, list := range watched {
+		go watchList(logger, list)
 	}
 
-	for _, u := range urls {
-		err = frontier.Put(u)
+	api := frontier.NewApiHandler(bfFrontier)
+	http.Handle("/frontier", api)
+	http.Handle("/frontier/", api)
+
+	var distributed *frontier.DistributedFrontier
+	var frontier frontier.Frontier = bfFrontier
+	if conf.Distributed.Addr != "" {
+		distributed = makeDistributedFrontier(logger, bfFrontier, conf.Distributed)
+		frontier = distributed
+	}
+
+	serveControl(logger, conf.Control, frontier)
+
+	for _, s := range seeds {
+		err = frontier.Put(s.url, s.meta)
 		if err != nil {
 			logger.Errorln(err)
 		}
 	}
 
-	fetcher := fetcher.NewDefaultFetcher(time.Duration(conf.Politeness.TimeoutMs) * time.Millisecond)
-	fc := filter.NewFilterChain()
-	fc.Append(filter.NewRobotsFilter(fetcher, 64), filter.NewRegexFilter(conf.CrawlScope))
+	timeout := time.Duration(conf.Politeness.TimeoutMs) * time.Millisecond
+	var fetcherOpts []fetcher.FetcherOption
+	if len(conf.Mime.AllowedTypes) > 0 {
+		fetcherOpts = append(fetcherOpts, fetcher.WithAllowedTypes(fetcher.NewMediaTypes(conf.Mime.AllowedTypes)))
+	}
+	httpFetcher := fetcher.NewDefaultFetcher(timeout, fetcherOpts...)
+	robotsFetcher := fetcher.NewDefaultFetcher(timeout)
+
+	parseTypes := conf.Mime.ParseTypes
+	if len(parseTypes) == 0 {
+		parseTypes = []string{"text/html", "application/xhtml+xml"}
+	}
 
 	processed := make(chan result, 32)
 	toProcess := make(chan resource, 32)
@@ -174,20 +486,138 @@ func main() {
 	warcWriter := warc.NewWarcWriter("data/warc/")
 
 	worker := &Worker{
-		fetcher:     fetcher,
+		fetcher:     httpFetcher,
 		in:          toProcess,
 		out:         processed,
 		warcWriter:  warcWriter,
 		maxPageSize: 100 * 1024 * 1024,
-		filterChain: fc,
+		parseTypes:  fetcher.NewMediaTypes(parseTypes),
 	}
-	worker.runN(context.Background(), &wg, 512)
-	loop(logger, processed, toProcess, frontier)
 
-	wg.Wait()
+	robotsOpts := []filter.RobotsOption{
+		filter.WithCrawlDelayHandler(bfFrontier.SetCrawlDelay),
+		filter.WithFetchHandler(func(u *url.URL, details *fetcher.FetchDetails) {
+			if err := worker.archive(u, details); err != nil {
+				logger.Errorf("Failed to archive robots.txt: %s - %s", u, err)
+			}
+		}),
+		filter.WithSitemapHandler(func(sitemaps []*url.URL) {
+			putSitemaps(logger, frontier, sitemaps)
+		}),
+	}
+	if conf.Robots.TtlMs > 0 {
+		robotsOpts = append(robotsOpts, filter.WithRobotsTTL(time.Duration(conf.Robots.TtlMs)*time.Millisecond))
+	}
+	if conf.Robots.MaxCrawlDelayMs > 0 {
+		robotsOpts = append(robotsOpts, filter.WithMaxCrawlDelay(time.Duration(conf.Robots.MaxCrawlDelayMs)*time.Millisecond))
+	}
+
+	robotsStorage := makeRobotsStorage(conf.Robots)
+	fc.Append("robots", filter.NewRobotsFilter(robotsFetcher, robotsStorage, robotsOpts...))
+	worker.filterChain = fc
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	worker.runN(workerCtx, &wg, 512)
+	crawlLogPath := conf.CrawlLog
+	if crawlLogPath == "" {
+		crawlLogPath = "data/crawl.log"
+	}
+	crawlLog, err := newCrawlLog(crawlLogPath)
+	if err != nil {
+		logger.Fatalf("Can't open crawl log: %s", err.Error())
+	}
+	defer crawlLog.Sync()
+
+	handled := make(chan struct{})
+	go func() {
+		handleResults(logger, crawlLog, processed, frontier, traps)
+		close(handled)
+	}()
+
+	dispatch(ctx, logger, toProcess, frontier)
+	// a second signal kills the process
+	stop()
+
+	shutdownTimeout := 30 * time.Second
+	if conf.ShutdownTimeoutMs > 0 {
+		shutdownTimeout = time.Duration(conf.ShutdownTimeoutMs) * time.Millisecond
+	}
+	logger.Infof("Shutting down, waiting up to %s for pages in flight", shutdownTimeout)
+
+	if !waitTimeout(&wg, shutdownTimeout) {
+		logger.Warnln("Pages still in flight after the shutdown timeout, canceling them")
+		cancelWorkers()
+		wg.Wait()
+	}
+	for r := range toProcess {
+		frontier.MarkRetry(r.u, r.meta, 0)
+	}
+	close(processed)
+	<-handled
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf("Failed to stop the HTTP server: %s", err.Error())
+	}
+
+	if err := worker.flushWarc(); err != nil {
+		logger.Errorf("Failed to flush WARC records: %s", err.Error())
+	}
+	if distributed != nil {
+		// other nodes must not reach the frontier once its databases are closed
+		if err := distributed.Stop(); err != nil {
+			logger.Errorf("Failed to stop the peer server: %s", err.Error())
+		}
+	}
+	if err := bfFrontier.Close(); err != nil {
+		logger.Errorf("Failed to flush the frontier: %s", err.Error())
+	}
+	if flusher, ok := robotsStorage.(storage.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			logger.Errorf("Failed to flush robots.txt cache: %s", err.Error())
+		}
+	}
+	closeDbs()
+	logger.Infoln("Stopped")
 }
 
-func makeDistributedFrontier(logger *zap.SugaredLogger, bfFrontier *frontier.BfFrontier, conf DistributedConf) frontier.Frontier {
+// waitTimeout waits for the group and reports whether it finished in time.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// serveControl registers the control API if a token is configured.
+func serveControl(logger *zap.SugaredLogger, conf ControlConf, f frontier.Frontier) {
+	if conf.Token == "" {
+		return
+	}
+
+	c, ok := f.(frontier.Controller)
+	if !ok {
+		logger.Warnln("The frontier can't be controlled, control API disabled")
+		return
+	}
+	http.Handle("/control/", frontier.NewControlHandler(c, conf.Token))
+}
+
+func makeDistributedFrontier(logger *zap.SugaredLogger, bfFrontier *frontier.BfFrontier, conf DistributedConf) *frontier.DistributedFrontier {
 	peer := p2p.NewPeer(logger.Desugar(), conf.Addr)
 
 	go peer.Listen(context.Background())
@@ -253,39 +683,224 @@ func bootstrap(logger *zap.SugaredLogger, addr string, distributedFrontier *fron
 	}
 }
 
-func makeFrontier(conf PolitenessConf) *frontier.BfFrontier {
-	qp, err := newPersistentQp("data/queues/")
+func makeScopeFilter(conf ScopeConf) (filter.FilterFunc, error) {
+	defaultAction := filter.Accept
+	if conf.Default != "" {
+		var err error
+		defaultAction, err = filter.ParseAction(conf.Default)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rules []filter.Rule
+	for i, rc := range conf.Rules {
+		action, err := filter.ParseAction(rc.Action)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		rule, err := filter.NewRule(action, rc.Type, rc.Value)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return filter.NewScopeFilter(rules, defaultAction), nil
+}
+
+func makeRobotsStorage(conf RobotsConf) filter.RobotsStorage {
+	db, err := openRocksDB("data/robots/")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	cacheSize := 1024
+	if conf.CacheSize > 0 {
+		cacheSize = conf.CacheSize
+	}
+
+	persistentStorage := rocksdb.NewRocksdbStorage[filter.RobotsEntry](db)
+	return inmem.NewSlidingStorage(persistentStorage, uint(cacheSize))
+}
+
+func makeFrontier(conf *Config, enqueueFilter *filter.FilterChain, traps *filter.TrapDetector) (*frontier.BfFrontier, error) {
+	var priority bool
+	switch conf.Politeness.QueueOrder {
+	case "", "fifo":
+	case "priority":
+		priority = true
+	default:
+		return nil, fmt.Errorf("Unknown queue order: %q", conf.Politeness.QueueOrder)
+	}
+
+	var exactSeen bool
+	switch conf.Seen.Mode {
+	case "", "bloom":
+	case "exact":
+		exactSeen = true
+	default:
+		return nil, fmt.Errorf("Unknown seen mode: %q", conf.Seen.Mode)
+	}
+
+	group, err := frontier.ParsePolitenessGroup(conf.Politeness.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[string]float64, len(conf.Politeness.Weights))
+	for _, w := range conf.Politeness.Weights {
+		weights[w.Domain] = w.Weight
+	}
+	scheduler, err := frontier.NewScheduler(conf.Politeness.Scheduler, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	qp, err := newPersistentQp("data/queues/", priority)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	bloomDb, err := grocksdb.OpenDb(getDbOpts(), "data/bloom/")
+	bloomDb, cfs, err := createDefaultDBWithCF("data/bloom/", []string{"quota", "recrawl", "schedule", "leases", "health", "seen"})
 	if err != nil {
 		panic(err.Error())
 	}
+	quotaCF := cfs[0]
+	recrawlCF := cfs[1]
+	scheduleCF := cfs[2]
+	leasesCF := cfs[3]
+	healthCF := cfs[4]
+	seenCF := cfs[5]
 
-	persistentStorage := rocksdb.NewRocksdbStorageWithEncoderDecoder[*boom.ScalableBloomFilter](bloomDb, encode, decode)
-	storage := inmem.NewSlidingStorage(persistentStorage, 1024)
-	//storage := inmem.NewInMemoryStorage[*boom.ScalableBloomFilter]()
+	storage := rocksdb.NewRocksdbStorageWithEncoderDecoder[*boom.ScalableBloomFilter](bloomDb, encode, decode)
 	queues, err := qp.GetAll()
 	if err != nil {
 		panic(err)
 	}
 
-	opts := []frontier.BfFrontierOption{}
-	if conf.DefaultSessionBudget > 0 {
-		opts = append(opts, frontier.WithSessionBudget(conf.DefaultSessionBudget))
+	quotaStorage := rocksdb.NewRocksdbStorage[frontier.QuotaStats](bloomDb, rocksdb.WithCF(quotaCF))
+	leaseStorage := rocksdb.NewRocksdbStorage[frontier.Lease](bloomDb, rocksdb.WithCF(leasesCF))
+
+	opts := []frontier.BfFrontierOption{
+		frontier.WithMaxDepth(uint32(conf.Scope.MaxDepth)),
+		frontier.WithEnqueueFilter(func(u *url.URL) (bool, string) {
+			d := enqueueFilter.Check(u)
+			return d.Accepted(), d.Filter
+		}),
+		frontier.WithQuotaStorage(quotaStorage),
+		frontier.WithLeaseStorage(leaseStorage),
+		frontier.WithStateStorage(queueStates{qp.metadataStorage}),
+		frontier.WithScheduler(scheduler),
+		frontier.WithHostQuota(frontier.Quota{
+			MaxPages: uint64(conf.Quota.Host.MaxPages),
+			MaxBytes: uint64(conf.Quota.Host.MaxBytes),
+		}),
+		frontier.WithDomainQuota(frontier.Quota{
+			MaxPages: uint64(conf.Quota.Domain.MaxPages),
+			MaxBytes: uint64(conf.Quota.Domain.MaxBytes),
+		}),
+	}
+	if conf.Quota.Action != "" {
+		action, err := frontier.ParseQuotaAction(conf.Quota.Action)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, frontier.WithQuotaAction(action))
+	}
+	if exactSeen {
+		opts = append(opts, frontier.WithExactSeen(rocksdb.NewRocksdbSet(bloomDb, rocksdb.WithCF(seenCF))))
+	}
+	if conf.Seen.CacheSize > 0 {
+		opts = append(opts, frontier.WithSeenCacheSize(uint(conf.Seen.CacheSize)))
+	}
+	if conf.Seen.FlushIntervalMs > 0 {
+		opts = append(opts, frontier.WithSeenFlushInterval(time.Duration(conf.Seen.FlushIntervalMs)*time.Millisecond))
+	}
+	if priority {
+		opts = append(opts, frontier.WithInlinkTracking(1_000_000))
+	}
+	if group != frontier.GroupHost {
+		opts = append(opts, frontier.WithPolitenessGroup(group))
+	}
+	if conf.Recrawl.Enabled {
+		policy := frontier.DefaultRecrawlPolicy()
+		if conf.Recrawl.InitialIntervalMs > 0 {
+			policy.Initial = time.Duration(conf.Recrawl.InitialIntervalMs) * time.Millisecond
+		}
+		if conf.Recrawl.MinIntervalMs > 0 {
+			policy.Min = time.Duration(conf.Recrawl.MinIntervalMs) * time.Millisecond
+		}
+		if conf.Recrawl.MaxIntervalMs > 0 {
+			policy.Max = time.Duration(conf.Recrawl.MaxIntervalMs) * time.Millisecond
+		}
+
+		states := rocksdb.NewRocksdbStorage[frontier.RecrawlState](bloomDb, rocksdb.WithCF(recrawlCF))
+		scheduleStorage := rocksdb.NewRocksdbStorage[frontier.ScheduledUrl](bloomDb, rocksdb.WithCF(scheduleCF))
+		schedule := rocksdb.NewRocksdbPriorityQueue(scheduleStorage, []byte("schedule"), frontier.ScheduledUrl.Score)
+		opts = append(opts, frontier.WithRecrawl(policy), frontier.WithRecrawlStorage(states, schedule))
+	}
+	if conf.Breaker.Enabled {
+		policy := frontier.DefaultBreakerPolicy()
+		if conf.Breaker.CooldownAfter > 0 {
+			policy.CooldownAfter = uint32(conf.Breaker.CooldownAfter)
+		}
+		if conf.Breaker.RetireAfter > 0 {
+			policy.RetireAfter = uint32(conf.Breaker.RetireAfter)
+		}
+		if conf.Breaker.CooldownMs > 0 {
+			policy.Cooldown = time.Duration(conf.Breaker.CooldownMs) * time.Millisecond
+		}
+		if conf.Breaker.MaxCooldownMs > 0 {
+			policy.MaxCooldown = time.Duration(conf.Breaker.MaxCooldownMs) * time.Millisecond
+		}
+
+		healthStorage := rocksdb.NewRocksdbStorage[frontier.HostHealth](bloomDb, rocksdb.WithCF(healthCF))
+		opts = append(opts, frontier.WithCircuitBreaker(policy), frontier.WithHealthStorage(healthStorage))
 	}
-	if conf.Multiplier > 0 {
-		opts = append(opts, frontier.WithPolitenessMultiplier(conf.Multiplier))
+	if conf.CheckpointIntervalMs > 0 {
+		opts = append(opts, frontier.WithCheckpointInterval(time.Duration(conf.CheckpointIntervalMs)*time.Millisecond))
 	}
-	if conf.MaxActiveQueues > 0 {
-		opts = append(opts, frontier.WithMaxActiveQueues(conf.MaxActiveQueues))
+	if conf.LeaseTtlMs > 0 {
+		opts = append(opts, frontier.WithLeaseTTL(time.Duration(conf.LeaseTtlMs)*time.Millisecond))
+	}
+	if conf.Politeness.DefaultSessionBudget > 0 {
+		opts = append(opts, frontier.WithSessionBudget(conf.Politeness.DefaultSessionBudget))
+	}
+	if conf.Politeness.Multiplier > 0 {
+		opts = append(opts, frontier.WithPolitenessMultiplier(conf.Politeness.Multiplier))
+	}
+	if len(conf.Politeness.MaxInflight) > 0 {
+		limits := make(map[string]int, len(conf.Politeness.MaxInflight))
+		for _, l := range conf.Politeness.MaxInflight {
+			limits[l.Domain] = l.Max
+		}
+		opts = append(opts, frontier.WithMaxInflight(limits))
+	}
+	if len(conf.Politeness.Windows) > 0 {
+		windows := make(map[string][]frontier.CrawlWindow)
+		for _, w := range conf.Politeness.Windows {
+			window, err := frontier.ParseCrawlWindow(w.Days, w.Hours, w.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid crawl window of %s: %w", w.Domain, err)
+			}
+			windows[w.Domain] = append(windows[w.Domain], window)
+		}
+		opts = append(opts, frontier.WithCrawlWindows(windows))
+	}
+	if traps != nil {
+		opts = append(opts, frontier.WithOnEnqueued(traps.Discovered))
+	}
+	if conf.Politeness.MaxActiveQueues > 0 {
+		opts = append(opts, frontier.WithMaxActiveQueues(conf.Politeness.MaxActiveQueues))
 	}
 
 	frontier := frontier.NewBfFrontier(qp, storage, opts...)
-	frontier.LoadQueues(queues)
-	return frontier
+	if err := frontier.LoadQueues(queues); err != nil {
+		return nil, err
+	}
+	return frontier, nil
 }
 
 func encode(bloom *boom.ScalableBloomFilter) ([]byte, error) {
@@ -317,47 +932,201 @@ func getDbOpts() *grocksdb.Options {
 }
 
 func openRocksDB(path string) (*grocksdb.DB, error) {
-	return grocksdb.OpenDb(getDbOpts(), path)
+	db, err := grocksdb.OpenDb(getDbOpts(), path)
+	if err != nil {
+		return nil, err
+	}
+	trackDb(db, nil)
+	return db, nil
 }
 
-func loop(logger *zap.SugaredLogger, processed chan result, urls chan resource, frontier frontier.Frontier) {
+func watchList(logger *zap.SugaredLogger, list *filter.WatchedList) {
+	if err := list.Watch(context.Background()); err != nil {
+		logger.Errorf("Can't watch list for changes: %s", err.Error())
+	}
+}
 
-	go func() {
-		for r := range processed {
-			total.Inc()
-			if r.err != nil {
-				if r.err != ErrCrawlForbidden {
-					logger.Errorf("Error processing url: %s - %s", r.url, r.err)
-				}
+func makeTrapDetector(logger *zap.Logger, conf TrapsConf) *filter.TrapDetector {
+	opts := []filter.TrapOption{
+		filter.WithAutoExclude(conf.AutoExclude),
+		filter.WithDiscoveryRatioExclude(conf.ExcludeOnRatio),
+	}
+	if conf.MaxPathDepth > 0 {
+		opts = append(opts, filter.WithMaxPathDepth(conf.MaxPathDepth))
+	}
+	if conf.MaxRepeats > 0 {
+		opts = append(opts, filter.WithMaxRepeats(conf.MaxRepeats))
+	}
+	if conf.MaxCalendarDates > 0 {
+		opts = append(opts, filter.WithMaxCalendarDates(conf.MaxCalendarDates))
+	}
+	if conf.MaxQueryCombinations > 0 {
+		opts = append(opts, filter.WithMaxQueryCombinations(conf.MaxQueryCombinations))
+	}
+	if conf.MaxDiscoveryRatio > 0 && conf.MinDiscovered > 0 {
+		opts = append(opts, filter.WithMaxDiscoveryRatio(conf.MaxDiscoveryRatio, uint64(conf.MinDiscovered)))
+	}
+	return filter.NewTrapDetector(logger, opts...)
+}
 
-				if _, isReqErr := r.err.(*RequestError); isReqErr {
-					frontier.MarkFailed(r.url)
-				} else {
-					frontier.MarkProcessed(r.url)
-				}
-				continue
+// transfer merges the export at importPath into the frontier, then exports
+// the frontier to exportPath. Either can be empty.
+func transfer(logger *zap.SugaredLogger, f *frontier.BfFrontier, importPath string, exportPath string) error {
+	if importPath != "" {
+		file, err := os.Open(importPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		stats, err := f.Import(bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("Failed to import %s: %w", importPath, err)
+		}
+		logger.Infof("Imported %d hosts and %d urls from %s", stats.Hosts, stats.Urls, importPath)
+		if stats.SkippedSeen > 0 {
+			logger.Warnf("Kept the local seen urls of %d hosts instead of the imported ones", stats.SkippedSeen)
+		}
+		if stats.SkippedRecrawl > 0 {
+			logger.Warnf("Skipped the recrawl state of %d urls that are known here or with recrawl disabled", stats.SkippedRecrawl)
+		}
+	}
+
+	if exportPath != "" {
+		file, err := os.Create(exportPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w := bufio.NewWriter(file)
+		stats, err := f.Export(w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to export to %s: %w", exportPath, err)
+		}
+		logger.Infof("Exported %d hosts, %d urls (%d in flight) and the recrawl state of %d urls to %s", stats.Hosts, stats.Urls, stats.InFlight, stats.Recrawl, exportPath)
+	}
+	return nil
+}
+
+// handleResults updates the frontier with the results of the workers until
+// the channel is closed.
+func handleResults(logger *zap.SugaredLogger, crawlLog *zap.Logger, processed chan result, frontier frontier.Frontier, traps *filter.TrapDetector) {
+	for r := range processed {
+		if errors.Is(r.err, context.Canceled) {
+			// keep it queued for the next run
+			frontier.MarkRetry(r.url, r.meta, 0)
+			continue
+		}
+
+		total.Inc()
+		if r.err != nil {
+			logger.Errorf("Error processing url: %s - %s", r.url, r.err)
+			crawlLog.Info("error", zap.String("url", r.url.String()), zap.Int("status", r.status), zap.Error(r.err))
+
+			if _, isReqErr := r.err.(*RequestError); isReqErr {
+				frontier.MarkFailed(r.url, r.err)
+			} else {
+				frontier.MarkProcessed(r.url)
 			}
+			continue
+		}
 
-			totalGood.Inc()
-			for _, u := range r.links {
-				err := frontier.Put(u)
-				if err != nil {
-					logger.Errorln(err.Error())
-				}
+		if d := r.decision; !d.Accepted() {
+			crawlLog.Info(d.Outcome.String(),
+				zap.String("url", r.url.String()),
+				zap.String("filter", d.Filter),
+				zap.String("reason", d.Reason),
+				zap.Duration("retry_after", d.RetryAfter),
+			)
+
+			if d.Outcome == filter.OutcomeRetry {
+				frontier.MarkRetry(r.url, r.meta, d.RetryAfter)
+			} else {
+				frontier.MarkProcessed(r.url)
 			}
-			frontier.MarkSuccessful(r.url, r.ttr)
+			continue
 		}
-	}()
 
+		totalGood.Inc()
+		crawlLog.Info("fetched",
+			zap.String("url", r.url.String()),
+			zap.Int("status", r.status),
+			zap.Int64("size", r.info.Size),
+			zap.Duration("ttr", r.info.TTR),
+			zap.Uint32("depth", r.meta.Depth),
+			zap.Int("links", len(r.links)+len(r.sitemap)),
+		)
+
+		child := r.meta.Child()
+		if traps != nil {
+			traps.Fetched(r.url)
+		}
+
+		for _, u := range r.links {
+			err := frontier.Put(u, child)
+			if err != nil {
+				logger.Errorln(err.Error())
+			}
+		}
+		for _, e := range r.sitemap {
+			err := frontier.Put(e.Url, r.meta.Listed(e.LastMod, e.Priority, e.Sitemap))
+			if err != nil {
+				logger.Errorln(err.Error())
+			}
+		}
+		frontier.MarkSuccessful(r.url, r.meta, r.info)
+	}
+}
+
+// putSitemaps queues the sitemaps listed by a robots.txt.
+func putSitemaps(logger *zap.SugaredLogger, f frontier.Frontier, sitemaps []*url.URL) {
+	for _, u := range sitemaps {
+		if err := f.Put(u, frontier.UrlMeta{Sitemap: true}); err != nil {
+			logger.Errorln(err.Error())
+		}
+	}
+}
+
+// dispatch hands urls from the frontier to the workers until the context is
+// done, then closes the channel.
+func dispatch(ctx context.Context, logger *zap.SugaredLogger, urls chan resource, frontier frontier.Frontier) {
+	defer close(urls)
+
+	var failures int
 	for {
-		url, accessAt, err := frontier.Get()
+		url, meta, accessAt, err := frontier.Get(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// back off when the frontier keeps failing
+			failures++
+			if failures > 10 {
+				logger.Debugf("Failed to get url: %s", err.Error())
+				select {
+				case <-time.After(time.Duration(min(failures, 100)) * time.Millisecond):
+				case <-ctx.Done():
+					return
+				}
+			}
 			continue
 		}
+		failures = 0
 
-		urls <- resource{
-			u:  url,
-			at: accessAt,
+		select {
+		case urls <- resource{
+			u:    url,
+			meta: meta,
+			at:   accessAt,
+		}:
+		case <-ctx.Done():
+			frontier.MarkRetry(url, meta, 0)
+			return
 		}
 	}
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"net/url"
 	"strconv"
 	"sync"
@@ -11,20 +10,26 @@ import (
 	warcparser "github.com/slyrz/warc"
 	"github.com/xunterr/aracno/internal/fetcher"
 	"github.com/xunterr/aracno/internal/filter"
+	"github.com/xunterr/aracno/internal/frontier"
 	"github.com/xunterr/aracno/internal/parser"
 	"github.com/xunterr/aracno/internal/warc"
 )
 
 type resource struct {
-	u  *url.URL
-	at time.Time
+	u    *url.URL
+	meta frontier.UrlMeta
+	at   time.Time
 }
 
 type result struct {
-	err   error
-	url   *url.URL
-	ttr   time.Duration
-	links []*url.URL
+	err      error
+	decision filter.Decision
+	url      *url.URL
+	meta     frontier.UrlMeta
+	status   int
+	info     frontier.FetchInfo
+	links    []*url.URL
+	sitemap  []parser.SitemapEntry
 }
 
 type RequestError struct {
@@ -35,6 +40,10 @@ func (re *RequestError) Error() string {
 	return re.Err.Error()
 }
 
+func (re *RequestError) Unwrap() error {
+	return re.Err
+}
+
 type Worker struct {
 	fetcher fetcher.Fetcher
 
@@ -44,12 +53,15 @@ type Worker struct {
 	warcWriter  *warc.WarcWriter
 	wwMu        sync.Mutex
 	maxPageSize int
+	parseTypes  fetcher.MediaTypes
 
 	filterChain *filter.FilterChain
 }
 
-var ErrCrawlForbidden error = errors.New("Crawl forbidden")
-var ErrTooBig error = errors.New("File is too big")
+var (
+	denyTooBig = filter.Decision{Outcome: filter.OutcomeReject, Filter: "size", Reason: "too_big"}
+	denyType   = filter.Decision{Outcome: filter.OutcomeReject, Filter: "mime", Reason: "type_not_allowed"}
+)
 
 func (w *Worker) runN(ctx context.Context, wg *sync.WaitGroup, n int) {
 	for i := 0; i < n; i++ {
@@ -64,11 +76,18 @@ func (w *Worker) runN(ctx context.Context, wg *sync.WaitGroup, n int) {
 func (w *Worker) run(ctx context.Context) {
 	for {
 		select {
-		case r := <-w.in:
-			if err := w.filter(r.u); err != nil {
+		case r, ok := <-w.in:
+			if !ok {
+				return
+			}
+
+			d, err := w.filter(r.u)
+			if err != nil || !d.Accepted() {
 				w.out <- result{
-					err: err,
-					url: r.u,
+					err:      err,
+					decision: d,
+					url:      r.u,
+					meta:     r.meta,
 				}
 				break
 			}
@@ -79,25 +98,22 @@ func (w *Worker) run(ctx context.Context) {
 	}
 }
 
-func (w *Worker) filter(url *url.URL) error {
-	ok, err := w.filterChain.Test(url)
-	if err != nil {
-		return ErrCrawlForbidden
-	}
-	if !ok {
-		return ErrCrawlForbidden
+func (w *Worker) filter(url *url.URL) (filter.Decision, error) {
+	if d := w.filterChain.Check(url); !d.Accepted() {
+		return d, nil
 	}
 
 	headRes, err := w.fetcher.Head(url)
 	if err != nil {
-		return &RequestError{Err: err}
+		return filter.Pass(), &RequestError{Err: err}
 	}
 	defer headRes.Body.Close()
 
 	if headRes.ContentLength > int64(w.maxPageSize) {
-		return ErrTooBig
+		filter.Observe(denyTooBig)
+		return denyTooBig, nil
 	}
-	return nil
+	return filter.Pass(), nil
 }
 
 func (w *Worker) waitAndProcess(ctx context.Context, res resource) result {
@@ -113,7 +129,9 @@ func (w *Worker) waitAndProcess(ctx context.Context, res resource) result {
 		return w.process(ctx, res)
 	case <-ctx.Done():
 		return result{
-			err: errors.New("Canceled"),
+			err:  ctx.Err(),
+			url:  res.u,
+			meta: res.meta,
 		}
 	}
 }
@@ -121,35 +139,78 @@ func (w *Worker) waitAndProcess(ctx context.Context, res resource) result {
 func (w *Worker) process(ctx context.Context, res resource) result {
 	details, err := w.fetcher.Fetch(res.u)
 	if err != nil {
+		if err == fetcher.ErrTypeNotAllowed {
+			filter.Observe(denyType)
+			return result{
+				decision: denyType,
+				url:      res.u,
+				meta:     res.meta,
+			}
+		}
+
 		return result{
 			err: &RequestError{Err: err},
 			url: res.u,
 		}
 	}
 
-	pageInfo, err := parser.ParsePage(res.u, details.Body)
+	var links []*url.URL
+	var sitemap []parser.SitemapEntry
+	if res.meta.Sitemap {
+		sitemap, err = parser.ParseSitemap(res.u, details.Body)
+	} else if w.parseTypes.Contains(fetcher.ContentMediaType(details.Header, details.Body)) {
+		var pageInfo *parser.PageInfo
+		if pageInfo, err = parser.ParsePage(res.u, details.Body); err == nil {
+			links = pageInfo.Links
+		}
+	}
 	if err != nil {
 		return result{
-			err: err,
-			url: res.u,
-			ttr: details.TTR,
+			err:    err,
+			url:    res.u,
+			status: details.StatusCode,
+			info:   fetchInfo(details),
 		}
 	}
 
-	w.wwMu.Lock()
-	err = writeWarc(w.warcWriter, res.u, details)
-	w.wwMu.Unlock()
+	err = w.archive(res.u, details)
 
 	return result{
-		err:   err,
-		url:   res.u,
-		ttr:   details.TTR,
-		links: pageInfo.Links,
+		err:     err,
+		url:     res.u,
+		meta:    res.meta,
+		status:  details.StatusCode,
+		info:    fetchInfo(details),
+		links:   links,
+		sitemap: sitemap,
+	}
+}
+
+func fetchInfo(details *fetcher.FetchDetails) frontier.FetchInfo {
+	return frontier.FetchInfo{
+		TTR:          details.TTR,
+		Size:         int64(len(details.Body)),
+		Digest:       fetcher.Digest(details.Body),
+		MaxAge:       fetcher.MaxAge(details.Header, time.Now()),
+		LastModified: fetcher.LastModified(details.Header),
 	}
 }
 
+// flushWarc writes the buffered WARC records to disk.
+func (w *Worker) flushWarc() error {
+	w.wwMu.Lock()
+	defer w.wwMu.Unlock()
+	return w.warcWriter.Flush()
+}
+
+func (w *Worker) archive(url *url.URL, details *fetcher.FetchDetails) error {
+	w.wwMu.Lock()
+	defer w.wwMu.Unlock()
+	return writeWarc(w.warcWriter, url, details)
+}
+
 func writeWarc(writer *warc.WarcWriter, url *url.URL, details *fetcher.FetchDetails) error {
-	respRecord, err := warc.ResourceRecord(details.Body, url.String(), "application/http")
+	respRecord, err := warc.ResourceRecord(details.Body, url.String(), fetcher.ContentMediaType(details.Header, details.Body))
 	if err != nil {
 		return err
 	}